@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/backups"
+	"github.com/pilatescomplete-bot/internal/credentials"
+	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/migrations"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  rotate-keys        re-encrypt stored credentials and tokens with the active encryption key
+  migrations status  list migrations and when they were applied
+  migrations up      apply pending migrations, pass --dry-run to only check them
+  backup             write a backup of the database into a file
+  restore            restore a backup into an empty database directory
+
+The server must be stopped before running a command, as the database can only be opened by one process.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	var err error
+	switch os.Args[1] {
+	case "rotate-keys":
+		err = rotateKeys(ctx, os.Args[2:])
+	case "migrations":
+		err = runMigrations(os.Args[2:])
+	case "backup":
+		err = backup(os.Args[2:])
+	case "restore":
+		err = restore(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("[ERROR] %s: %s", os.Args[1], err)
+	}
+}
+
+func rotateKeys(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	dbPath := flags.String("database-path", "pilatedcomplete.db", "path to the database")
+	key := flags.String("encryption-key", defaultEncryptionKey, "encryption key for the database, see keys.ParseKey for supported formats")
+	keyring := flags.String("encryption-keys", "", "comma separated list of id:key encryption keys, the first one is active. overrides encryption-key")
+	insecure := flags.Bool("insecure", false, "if true, will allow to re-encrypt with the default encryption key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if envKey := os.Getenv("ENCRYPTION_KEY"); envKey != "" {
+		key = &envKey
+	}
+
+	if envKeys := os.Getenv("ENCRYPTION_KEYS"); envKeys != "" {
+		keyring = &envKeys
+	}
+
+	if *keyring == "" && *key == defaultEncryptionKey && !*insecure {
+		return fmt.Errorf("refusing to re-encrypt with the default key, set a key or pass --insecure")
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(*dbPath))
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	defer db.Close()
+
+	// stores expect the current layout, as they do in the server
+	if err := migrations.Run(kv.NewBadger(db)); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+
+	salt, err := keys.NewStore(kv.NewBadger(db)).GetOrCreateSalt(ctx)
+	if err != nil {
+		return fmt.Errorf("salt: %w", err)
+	}
+
+	encryptionKeys, err := keys.ParseKeyringOrKey(*key, *keyring, salt)
+	if err != nil {
+		return fmt.Errorf("encryption keys: %w", err)
+	}
+
+	credentialsCount, err := credentials.NewStore(kv.NewBadger(db), encryptionKeys).Reencrypt(ctx)
+	if err != nil {
+		return fmt.Errorf("reencrypt credentials: %w", err)
+	}
+	log.Printf("[INFO] re-encrypted %d credentials with key %q", credentialsCount, encryptionKeys.ActiveID())
+
+	tokensCount, err := tokens.NewStore(kv.NewBadger(db), encryptionKeys).Reencrypt(ctx)
+	if err != nil {
+		return fmt.Errorf("reencrypt tokens: %w", err)
+	}
+	log.Printf("[INFO] re-encrypted %d tokens with key %q", tokensCount, encryptionKeys.ActiveID())
+
+	return nil
+}
+
+func runMigrations(args []string) error {
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("migrations", flag.ExitOnError)
+	dbPath := flags.String("database-path", "pilatedcomplete.db", "path to the database")
+	dryRun := flags.Bool("dry-run", false, "if true, will apply migrations in a transaction that is discarded")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(*dbPath))
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrations.ListStatuses(kv.NewBadger(db))
+		if err != nil {
+			return fmt.Errorf("list statuses: %w", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied != nil {
+				appliedAt = status.Applied.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.ID, status.Name, appliedAt)
+		}
+		return w.Flush()
+	case "up":
+		if *dryRun {
+			pending, err := migrations.DryRun(kv.NewBadger(db))
+			if err != nil {
+				return fmt.Errorf("dry run: %w", err)
+			}
+			for _, migration := range pending {
+				log.Printf("[INFO] would apply migration %d %q", migration.ID, migration.Name)
+			}
+			log.Printf("[INFO] %d pending migrations", len(pending))
+			return nil
+		}
+		return migrations.Run(kv.NewBadger(db))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+		return nil
+	}
+}
+
+func backup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbPath := flags.String("database-path", "pilatedcomplete.db", "path to the database")
+	output := flags.String("output", "", "path to the backup file")
+	key := flags.String("backup-encryption-key", "", "encryption key for the backup, backup is not encrypted if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if envKey := os.Getenv("BACKUP_ENCRYPTION_KEY"); envKey != "" {
+		key = &envKey
+	}
+
+	if *output == "" {
+		return fmt.Errorf("output is required")
+	}
+
+	backupKey, err := parseBackupKey(*key)
+	if err != nil {
+		return fmt.Errorf("backup-encryption-key: %w", err)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(*dbPath))
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	defer db.Close()
+
+	if err := backups.WriteFile(db, *output, backupKey); err != nil {
+		return err
+	}
+	log.Printf("[INFO] backup written to %s", *output)
+	return nil
+}
+
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbPath := flags.String("database-path", "pilatedcomplete.db", "path to the database, must not exist or be empty")
+	input := flags.String("input", "", "path to the backup file")
+	key := flags.String("backup-encryption-key", "", "encryption key of the backup")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if envKey := os.Getenv("BACKUP_ENCRYPTION_KEY"); envKey != "" {
+		key = &envKey
+	}
+
+	if *input == "" {
+		return fmt.Errorf("input is required")
+	}
+
+	backupKey, err := parseBackupKey(*key)
+	if err != nil {
+		return fmt.Errorf("backup-encryption-key: %w", err)
+	}
+
+	if entries, err := os.ReadDir(*dbPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read database directory: %w", err)
+	} else if len(entries) > 0 {
+		return fmt.Errorf("%s: database directory is not empty", *dbPath)
+	}
+
+	file, err := os.Open(*input)
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer file.Close()
+
+	db, err := badger.Open(badger.DefaultOptions(*dbPath))
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	defer db.Close()
+
+	if err := backups.Restore(db, file, backupKey); err != nil {
+		return err
+	}
+	log.Printf("[INFO] backup restored to %s", *dbPath)
+	return nil
+}
+
+const defaultEncryptionKey = "please-change-me"
+
+func parseBackupKey(key string) (*keys.Key, error) {
+	if key == "" {
+		return nil, nil
+	}
+	return keys.ParseKey([]byte(key), nil)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -10,24 +11,34 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/pilatescomplete-bot/internal/authentication"
+	"github.com/pilatescomplete-bot/internal/backups"
 	"github.com/pilatescomplete-bot/internal/calendars"
 	"github.com/pilatescomplete-bot/internal/credentials"
 	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/goals"
 	httpx "github.com/pilatescomplete-bot/internal/http"
 	"github.com/pilatescomplete-bot/internal/http/static"
 	"github.com/pilatescomplete-bot/internal/http/templates"
 	"github.com/pilatescomplete-bot/internal/jobs"
 	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/leases"
+	"github.com/pilatescomplete-bot/internal/maintenance"
 	"github.com/pilatescomplete-bot/internal/migrations"
 	"github.com/pilatescomplete-bot/internal/notifications"
 	"github.com/pilatescomplete-bot/internal/pilatescomplete"
+	"github.com/pilatescomplete-bot/internal/planner"
+	"github.com/pilatescomplete-bot/internal/settings"
 	"github.com/pilatescomplete-bot/internal/statistics"
 	"github.com/pilatescomplete-bot/internal/telegram"
+	"github.com/pilatescomplete-bot/internal/timetable"
 	"github.com/pilatescomplete-bot/internal/tokens"
 	"golang.org/x/sync/errgroup"
 )
@@ -35,36 +46,198 @@ import (
 func main() {
 	addr := flag.String("address", ":http", "http address to listen to")
 	dbPath := flag.String("database-path", "pilatedcomplete.db", "path to the database")
-	key := flag.String("encryption-key", "please-change-me", "encryption key for the database")
+	key := flag.String("encryption-key", defaultEncryptionKey, "encryption key for the database, see keys.ParseKey for supported formats")
+	keyring := flag.String("encryption-keys", "", "comma separated list of id:key encryption keys, the first one is active. overrides encryption-key")
+	insecure := flag.Bool("insecure", false, "if true, will allow to run with the default encryption key")
 	watch := flag.Bool("watch", false, "if true, will serve from filesystem")
 	telegramBotToken := flag.String("telegram-bot-token", "", "Telegram bot token")
+	backupDir := flag.String("backup-dir", "", "directory to write periodic backups to, backups are disabled if empty")
+	backupInterval := flag.Duration("backup-interval", 24*time.Hour, "how often to write backups")
+	backupKeep := flag.Int("backup-keep", 7, "how many latest backups to keep")
+	backupKey := flag.String("backup-encryption-key", "", "encryption key for backups, backups are not encrypted if empty")
+	maintenanceInterval := flag.Duration("maintenance-interval", 6*time.Hour, "how often to clean up expired data")
+	tokensRetention := flag.Duration("tokens-retention", 0, "how long to keep tokens after they expire")
+	bookEventMaxDelay := flag.Duration("book-event-max-delay", 5*time.Minute, "how late a scheduled booking is still made, later bookings are marked as missed. 0 means no limit")
+	jobsRetention := flag.Duration("jobs-retention", 30*24*time.Hour, "how long to keep finished jobs before archiving them")
+	clockProbeInterval := flag.Duration("clock-probe-interval", 10*time.Minute, "how often to sample the booking server clock")
+	clockSkewThreshold := flag.Duration("clock-skew-threshold", 2*time.Second, "warn when the booking server clock differs from the local clock by more than this")
+	leaseTTL := flag.Duration("lease-ttl", 15*time.Second, "how long the scheduler lease is valid without renewal")
+	plannerInterval := flag.Duration("planner-interval", 5*time.Minute, "how often to release queued bookings of users at their membership limits")
+	statisticsSyncInterval := flag.Duration("statistics-sync-interval", time.Hour, "how often to sync statistics from notifications")
+	timetableSnapshotInterval := flag.Duration("timetable-snapshot-interval", time.Minute, "how often to snapshot places of the timetable to learn how fast classes sell out")
+	timetableRetention := flag.Duration("timetable-retention", 90*24*time.Hour, "how long to keep timetable snapshots of started classes")
+	goalsCheckInterval := flag.Duration("goals-check-interval", time.Hour, "how often to check if weekly goals are behind halfway through the week")
+	advertiseAddress := flag.String("advertise-address", "", "address other instances forward requests to while this instance holds the lease, e.g. http://10.0.0.1:80")
 	flag.Parse()
 
 	if envKey := os.Getenv("ENCRYPTION_KEY"); envKey != "" {
 		key = &envKey
 	}
 
+	if envKeys := os.Getenv("ENCRYPTION_KEYS"); envKeys != "" {
+		keyring = &envKeys
+	}
+
 	if envKey := os.Getenv("TELEGRAM_BOT_TOKEN"); envKey != "" {
 		telegramBotToken = &envKey
 	}
 
-	encryptionKey, err := keys.ParseKey([]byte(*key))
-	if err != nil {
-		log.Fatalf("[ERROR] encryption-key: %s", err)
+	if envKey := os.Getenv("BACKUP_ENCRYPTION_KEY"); envKey != "" {
+		backupKey = &envKey
+	}
+
+	if *backupKeep < 1 {
+		log.Fatalf("[ERROR] backup-keep: must keep at least 1 backup, got %d", *backupKeep)
+	}
+
+	if *keyring == "" && *key == defaultEncryptionKey && !*insecure {
+		log.Fatalf("[ERROR] encryption-key: refusing to run with the default key, set a key or pass --insecure")
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	db, err := badger.Open(badger.DefaultOptions(*dbPath))
+	hostname, err := os.Hostname()
 	if err != nil {
-		log.Fatalf("[ERROR] db: %s", err)
+		log.Fatalf("[ERROR] hostname: %s", err)
+	}
+	// only the lease holder opens the database, runs jobs and listens for telegram updates.
+	// other instances forward requests to it until they get the lease.
+	lease := leases.New(*dbPath+".lease", fmt.Sprintf("%s-%d", hostname, os.Getpid()), *advertiseAddress, *leaseTTL)
+
+	var serving atomic.Pointer[http.HandlerFunc]
+	followerHandler := httpx.FollowerHandler(lease)
+	serving.Store(&followerHandler)
+	httpServer := http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*serving.Load())(w, r)
+		}),
 	}
 
-	if err := migrations.Run(db); err != nil {
-		slog.ErrorContext(ctx, "migrations", "error", err)
+	// Wait for shut down in a separate goroutine.
+	shutdownCh := make(chan os.Signal, 1)
+	signal.Notify(shutdownCh, os.Interrupt, syscall.SIGTERM)
+	errCh := make(chan error)
+	go func() {
+		sig := <-shutdownCh
+
+		log.Printf("[INFO] received %s, shutting down", sig)
+		cancel()
+
+		shutdownTimeout := 15 * time.Second
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		errCh <- httpServer.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		slog.ErrorContext(ctx, "listen", "error", err)
 		os.Exit(1)
 	}
+	slog.InfoContext(ctx, "listen", "address", ln.Addr())
+
+	errGroup := errgroup.Group{}
+	errGroup.Go(func() error {
+		if err := httpServer.Serve(ln); err != http.ErrServerClosed {
+			return fmt.Errorf("http serve: %w", err)
+		}
+		return nil
+	})
+
+	var db *badger.DB
+	// wait waits for everything to stop, then closes the database and releases the lease, so that another instance
+	// can take over right away, also when stopping because of an error.
+	wait := func() {
+		exitCode := 0
+		if err := errGroup.Wait(); err != nil {
+			slog.ErrorContext(ctx, "error", "error", err)
+			exitCode = 1
+			// make sure the http server is shut down, unless it already is
+			select {
+			case shutdownCh <- syscall.SIGTERM:
+			default:
+			}
+		}
+
+		if err := <-errCh; err != nil {
+			slog.ErrorContext(ctx, "shutdown", "error", err)
+			exitCode = 1
+		}
+
+		if db != nil {
+			if err := db.Close(); err != nil {
+				slog.ErrorContext(ctx, "close db", "error", err)
+				exitCode = 1
+			}
+		}
+		if err := lease.Release(); err != nil {
+			slog.ErrorContext(ctx, "release lease", "error", err)
+			exitCode = 1
+		}
+
+		slog.InfoContext(ctx, "application stopped")
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}
+
+	// fail stops everything because of the error, waits for it to stop and exits with a non-zero code.
+	fail := func(name string, err error) {
+		errGroup.Go(func() error {
+			return fmt.Errorf("%s: %w", name, err)
+		})
+		select {
+		case shutdownCh <- syscall.SIGTERM:
+		default:
+		}
+		wait()
+	}
+
+	if err := lease.Acquire(ctx); err != nil {
+		// shutting down before the lease was acquired
+		wait()
+		return
+	}
+	errGroup.Go(func() error {
+		if err := lease.Keep(ctx); err != nil {
+			// stop everything as soon as possible, another instance is running jobs now
+			select {
+			case shutdownCh <- syscall.SIGTERM:
+			default:
+			}
+			return fmt.Errorf("lease: %w", err)
+		}
+		return nil
+	})
+
+	db, err = openDatabase(ctx, *dbPath)
+	if errors.Is(err, context.Canceled) {
+		wait()
+		return
+	} else if err != nil {
+		fail("db", err)
+		return
+	}
+	store := kv.NewBadger(db)
+
+	if err := migrations.Run(store); err != nil {
+		fail("migrations", err)
+		return
+	}
+
+	salt, err := keys.NewStore(store).GetOrCreateSalt(ctx)
+	if err != nil {
+		fail("salt", err)
+		return
+	}
+
+	encryptionKeys, err := keys.ParseKeyringOrKey(*key, *keyring, salt)
+	if err != nil {
+		fail("encryption keys", err)
+		return
+	}
 
 	var renderer templates.Renderer
 	var staticHandler http.Handler
@@ -76,26 +249,73 @@ func main() {
 		staticHandler = static.NewEmbedHandler()
 	}
 
-	credentialsStore := credentials.NewStore(db, encryptionKey)
-	tokensStore := tokens.NewStore(db, encryptionKey)
-	jobsStore := jobs.NewStore(db)
+	credentialsStore := credentials.NewStore(store, encryptionKeys)
+	tokensStore := tokens.NewStore(store, encryptionKeys)
+	jobsStore := jobs.NewStore(store)
+	settingsStore := settings.NewStore(store)
 	apiClient := pilatescomplete.NewAPIClient()
 	authenticationService := authentication.NewService(tokensStore, credentialsStore, apiClient)
-	eventsService := events.NewService(jobsStore, apiClient)
+	eventsService := events.NewService(jobsStore, settingsStore, apiClient)
 
 	var handler slog.Handler
 	handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: new(slog.LevelVar),
 	})
 
-	errGroup := errgroup.Group{}
+	if *backupDir != "" {
+		var backupEncryptionKey *keys.Key
+		if *backupKey != "" {
+			backupEncryptionKey, err = keys.ParseKey([]byte(*backupKey), nil)
+			if err != nil {
+				fail("backup-encryption-key", err)
+				return
+			}
+		}
+		backupsScheduler := backups.NewScheduler(db, backupEncryptionKey, *backupDir, *backupInterval, *backupKeep)
+		errGroup.Go(func() error {
+			if err := backupsScheduler.Run(ctx); err != nil {
+				return fmt.Errorf("backups: %w", err)
+			}
+			return nil
+		})
+	}
+
+	maintenanceService := maintenance.NewService(db, tokensStore, jobsStore, *maintenanceInterval, *tokensRetention, *jobsRetention)
+	errGroup.Go(func() error {
+		if err := maintenanceService.Run(ctx); err != nil {
+			return fmt.Errorf("maintenance: %w", err)
+		}
+		return nil
+	})
+
 	scheduler := jobs.NewScheduler(jobsStore, apiClient, authenticationService)
+	scheduler.SetMisfirePolicy(jobs.TypeBookEvent, jobs.MisfirePolicy{
+		MaxDelay: *bookEventMaxDelay,
+	})
+	bookingsPlanner := planner.NewPlanner(jobsStore, scheduler, eventsService, settingsStore, authenticationService, *plannerInterval)
+	scheduler.OnJobQueued(func(ctx context.Context, job *jobs.Job) {
+		if job.BookEvent == nil || job.LastAttempt() == nil {
+			return
+		}
+		if err := bookingsPlanner.Learn(ctx, job.BookEvent.CredentialsID, job.BookEvent.EventStartTime, job.LastAttempt().ErrorCode); err != nil {
+			slog.ErrorContext(ctx, "learn booking limits", "error", err)
+		}
+	})
+	errGroup.Go(func() error {
+		if err := bookingsPlanner.Run(ctx); err != nil {
+			return fmt.Errorf("planner: %w", err)
+		}
+		return nil
+	})
+	var telegramBot *telegram.Bot
 	if *telegramBotToken != "" {
-		telegramStore := telegram.NewStore(db)
-		telegramBot, err := telegram.NewBot(authenticationService, eventsService, telegramStore, *telegramBotToken)
+		telegramStore := telegram.NewStore(store)
+		bot, err := telegram.NewBot(authenticationService, eventsService, telegramStore, *telegramBotToken)
 		if err != nil {
-			log.Fatalf("[ERROR] telegram bot: %s", err)
+			fail("telegram bot", err)
+			return
 		}
+		telegramBot = bot
 
 		handler = telegram.NewSlogHandler(telegramBot, handler)
 		scheduler.OnJobFailed(func(ctx context.Context, job *jobs.Job) {
@@ -112,6 +332,13 @@ func main() {
 				}
 			}
 		})
+		scheduler.OnJobMissed(func(ctx context.Context, job *jobs.Job) {
+			if job.BookEvent != nil {
+				if err := telegramBot.BroadcastBookEventMissed(ctx, job); err != nil {
+					slog.ErrorContext(ctx, "broadcast book event missed", "error", err)
+				}
+			}
+		})
 
 		errGroup.Go(func() error {
 			if err := telegramBot.Listen(ctx); err != nil {
@@ -124,13 +351,50 @@ func main() {
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
-	calendarsStore := calendars.NewStore(db)
+	// started after the default logger is set, so that warnings are sent to telegram
+	errGroup.Go(func() error {
+		if err := apiClient.WatchClock(ctx, *clockProbeInterval, *clockSkewThreshold); err != nil {
+			return fmt.Errorf("watch clock: %w", err)
+		}
+		return nil
+	})
+
+	calendarsStore := calendars.NewStore(store)
 	calendarsService := calendars.NewService(calendarsStore, authenticationService, eventsService)
-	notificationsService := notifications.NewService(apiClient)
-	statisticsService := statistics.NewService(notificationsService)
+	notificationsService := notifications.NewService(apiClient, notifications.NewStore(store), eventsService)
+	statisticsStore := statistics.NewStore(store)
+	statisticsService := statistics.NewService(notificationsService, statisticsStore, authenticationService, credentialsStore, eventsService)
+	errGroup.Go(func() error {
+		if err := statisticsService.Run(ctx, *statisticsSyncInterval); err != nil {
+			return fmt.Errorf("statistics: %w", err)
+		}
+		return nil
+	})
+	timetableService := timetable.NewService(timetable.NewStore(store), eventsService, authenticationService, credentialsStore)
+	errGroup.Go(func() error {
+		if err := timetableService.Run(ctx, *timetableSnapshotInterval, *timetableRetention); err != nil {
+			return fmt.Errorf("timetable: %w", err)
+		}
+		return nil
+	})
+	goalsStore := goals.NewStore(store)
+	goalsService := goals.NewService(goalsStore, statisticsService, authenticationService, credentialsStore)
+	if telegramBot != nil {
+		goalsService.OnBehind(func(ctx context.Context, progress *goals.Progress) {
+			if err := telegramBot.SendGoalBehind(ctx, progress); err != nil {
+				slog.ErrorContext(ctx, "send goal behind", "error", err)
+			}
+		})
+	}
+	errGroup.Go(func() error {
+		if err := goalsService.Run(ctx, *goalsCheckInterval); err != nil {
+			return fmt.Errorf("goals: %w", err)
+		}
+		return nil
+	})
 	if err := scheduler.Init(ctx); err != nil {
-		log.Fatalf("[ERROR] scheduler init: %s", err)
-		os.Exit(1)
+		fail("scheduler init", err)
+		return
 	}
 	htmlHandler := httpx.Handler(
 		renderer,
@@ -138,56 +402,46 @@ func main() {
 		apiClient,
 		tokensStore,
 		credentialsStore,
+		settingsStore,
 		authenticationService,
 		eventsService,
 		scheduler,
+		bookingsPlanner,
 		calendarsService,
 		statisticsService,
+		goalsStore,
+		goalsService,
+		timetableService,
+		notificationsService,
+		telegramBot,
+		*clockSkewThreshold,
 	)
 
-	httpServer := http.Server{
-		Handler: htmlHandler,
-	}
-
-	// Wait for shut down in a separate goroutine.
-	errCh := make(chan error)
-	go func() {
-		shutdownCh := make(chan os.Signal, 1)
-		signal.Notify(shutdownCh, os.Interrupt, syscall.SIGTERM)
-		sig := <-shutdownCh
+	serving.Store(&htmlHandler)
 
-		log.Printf("[INFO] received %s, shutting down", sig)
-		cancel()
-
-		shutdownTimeout := 15 * time.Second
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
-		defer cancel()
-
-		errCh <- httpServer.Shutdown(shutdownCtx)
-	}()
+	wait()
+}
 
-	ln, err := net.Listen("tcp", *addr)
-	if err != nil {
-		slog.ErrorContext(ctx, "listen", "error", err)
-		os.Exit(1)
-	}
-	slog.InfoContext(ctx, "listen", "address", ln.Addr())
+const defaultEncryptionKey = "please-change-me"
 
-	errGroup.Go(func() error {
-		if err := httpServer.Serve(ln); err != http.ErrServerClosed {
-			return fmt.Errorf("http serve: %w", err)
+// openDatabase opens the database, waiting for the previous lease holder to close it.
+func openDatabase(ctx context.Context, path string) (*badger.DB, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		db, err := badger.Open(badger.DefaultOptions(path))
+		if err == nil {
+			return db, nil
+		}
+		// badger does not wrap the underlying error
+		if !strings.Contains(err.Error(), "Cannot acquire directory lock") {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "database is locked, waiting", "path", path)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
 		}
-		return nil
-	})
-	if err := errGroup.Wait(); err != nil {
-		slog.ErrorContext(ctx, "error", "error", err)
-		os.Exit(1)
-	}
-
-	if err := <-errCh; err != nil {
-		slog.ErrorContext(ctx, "shutdown", "error", err)
-		os.Exit(1)
 	}
-
-	slog.InfoContext(ctx, "application stopped")
 }
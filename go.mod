@@ -7,6 +7,7 @@ require (
 	github.com/dgraph-io/badger/v4 v4.3.0
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/matoous/go-nanoid/v2 v2.1.0
+	golang.org/x/crypto v0.36.0
 	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
 )
 
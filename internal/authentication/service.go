@@ -29,11 +29,19 @@ func NewService(
 }
 
 func (s *Service) AuthenticateContext(ctx context.Context, credentialsID string) (context.Context, error) {
+	ctx, _, err := s.Authenticate(ctx, credentialsID)
+	return ctx, err
+}
+
+// Authenticate returns a context with a token for the credentials id. Refreshed is true if there was no valid
+// token, and a new one was obtained by logging in.
+func (s *Service) Authenticate(ctx context.Context, credentialsID string) (context.Context, bool, error) {
+	refreshed := false
 	token, err := s.tokensStore.FindByID(ctx, credentialsID)
 	if errors.Is(err, tokens.ErrNotFound) {
 		creds, err := s.credentialsStore.FindByID(ctx, credentialsID)
 		if err != nil {
-			return ctx, fmt.Errorf("find credentials %q: %w", credentialsID, err)
+			return ctx, false, fmt.Errorf("find credentials %q: %w", credentialsID, err)
 		}
 
 		cookie, err := s.apiClient.Login(ctx, pilatescomplete.LoginData{
@@ -41,7 +49,7 @@ func (s *Service) AuthenticateContext(ctx context.Context, credentialsID string)
 			Password: creds.Password,
 		})
 		if err != nil {
-			return ctx, fmt.Errorf("login: %w", err)
+			return ctx, false, fmt.Errorf("login: %w", err)
 		}
 
 		token = &tokens.Token{
@@ -51,10 +59,11 @@ func (s *Service) AuthenticateContext(ctx context.Context, credentialsID string)
 		}
 
 		if err := s.tokensStore.Insert(ctx, token); err != nil {
-			return ctx, fmt.Errorf("insert token: %w", err)
+			return ctx, false, fmt.Errorf("insert token: %w", err)
 		}
+		refreshed = true
 	} else if err != nil {
-		return ctx, fmt.Errorf("find token by credentialsID %q: %w", credentialsID, err)
+		return ctx, false, fmt.Errorf("find token by credentialsID %q: %w", credentialsID, err)
 	}
-	return tokens.NewContext(ctx, token), nil
+	return tokens.NewContext(ctx, token), refreshed, nil
 }
@@ -0,0 +1,88 @@
+package backups
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/keys"
+)
+
+// encryptedHeader is written in front of encrypted backups.
+var encryptedHeader = []byte("pilatescomplete-backup:aes\n")
+
+var ErrKeyRequired = errors.New("backup is encrypted, key is required")
+
+// Write streams a full backup of the database into w. If key is not nil, backup is encrypted with it.
+func Write(db *badger.DB, w io.Writer, key *keys.Key) error {
+	if key != nil {
+		if _, err := w.Write(encryptedHeader); err != nil {
+			return fmt.Errorf("write header: %w", err)
+		}
+		encryptWriter, err := key.NewEncryptWriter(w)
+		if err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		w = encryptWriter
+	}
+	if _, err := db.Backup(w, 0); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+// Restore loads a backup written by Write into db. The database is expected to be empty.
+// Key is required to restore encrypted backups.
+func Restore(db *badger.DB, r io.Reader, key *keys.Key) error {
+	reader := bufio.NewReader(r)
+	header, err := reader.Peek(len(encryptedHeader))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read header: %w", err)
+	}
+
+	var backup io.Reader = reader
+	if bytes.Equal(header, encryptedHeader) {
+		if key == nil {
+			return ErrKeyRequired
+		}
+		if _, err := reader.Discard(len(encryptedHeader)); err != nil {
+			return fmt.Errorf("read header: %w", err)
+		}
+		backup, err = key.NewDecryptReader(reader)
+		if err != nil {
+			return fmt.Errorf("decrypt: %w", err)
+		}
+	}
+
+	if err := db.Load(backup, 256); err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	return nil
+}
+
+// WriteFile writes a backup of the database into path. Backup is written into a temporary file first,
+// so that an incomplete backup never replaces a complete one.
+func WriteFile(db *badger.DB, path string, key *keys.Key) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := Write(db, file, key); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return nil
+}
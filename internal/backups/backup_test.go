@@ -0,0 +1,63 @@
+package backups
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/keys"
+)
+
+func TestBackupRestore(t *testing.T) {
+	source, err := badger.Open(badger.DefaultOptions(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if err := source.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("key"), []byte("value"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := keys.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := &bytes.Buffer{}
+	if err := Write(source, backup, key); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := badger.Open(badger.DefaultOptions(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	if err := Restore(target, bytes.NewReader(backup.Bytes()), nil); !errors.Is(err, ErrKeyRequired) {
+		t.Fatalf("expected %q, got %q", ErrKeyRequired, err)
+	}
+
+	if err := Restore(target, bytes.NewReader(backup.Bytes()), key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("key"))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(value []byte) error {
+			if string(value) != "value" {
+				t.Fatalf("expected \"value\", got %q", value)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
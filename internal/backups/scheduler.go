@@ -0,0 +1,158 @@
+package backups
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/keys"
+)
+
+const (
+	filePrefix = "backup-"
+	fileSuffix = ".bak"
+	timeFormat = "20060102T150405Z"
+)
+
+// Scheduler periodically writes backups into a directory, keeping only a number of latest files.
+type Scheduler struct {
+	db       *badger.DB
+	key      *keys.Key
+	dir      string
+	interval time.Duration
+	keep     int
+}
+
+func NewScheduler(
+	db *badger.DB,
+	key *keys.Key,
+	dir string,
+	interval time.Duration,
+	keep int,
+) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		key:      key,
+		dir:      dir,
+		interval: interval,
+		keep:     keep,
+	}
+}
+
+// Run writes backups every interval until context is cancelled. A backup is also written on start if the latest one
+// is older than the interval, so that backups are written even if the instance is restarted more often than that.
+// Failures are logged and retried on the next tick, so that backups do not stop for good on one failure.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.backupIfStale(ctx); err != nil {
+		slog.ErrorContext(ctx, "backup", "dir", s.dir, "error", err)
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			path, err := s.Backup(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "backup", "dir", s.dir, "error", err)
+				continue
+			}
+			slog.InfoContext(ctx, "backup written", "path", path)
+		}
+	}
+}
+
+// backupIfStale writes a backup if the latest one is older than the interval.
+func (s *Scheduler) backupIfStale(ctx context.Context) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("create backups directory: %w", err)
+	}
+	latest, err := s.latest()
+	if err != nil {
+		return fmt.Errorf("latest backup: %w", err)
+	}
+	if time.Since(latest) < s.interval {
+		return nil
+	}
+	path, err := s.Backup(ctx)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "backup written", "path", path)
+	return nil
+}
+
+// Backup writes a new backup file and removes old ones. Returns path of the written file.
+func (s *Scheduler) Backup(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", fmt.Errorf("create backups directory: %w", err)
+	}
+	path := filepath.Join(s.dir, filePrefix+time.Now().UTC().Format(timeFormat)+fileSuffix)
+	if err := WriteFile(s.db, path, s.key); err != nil {
+		return "", err
+	}
+	if err := s.rotate(ctx); err != nil {
+		return "", fmt.Errorf("rotate: %w", err)
+	}
+	return path, nil
+}
+
+// latest returns time of the latest backup, zero if there are none.
+func (s *Scheduler) latest() (time.Time, error) {
+	backups, err := s.list()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		name := strings.TrimSuffix(strings.TrimPrefix(backups[i], filePrefix), fileSuffix)
+		if ts, err := time.Parse(timeFormat, name); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// list returns names of backup files, from oldest to newest.
+func (s *Scheduler) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), filePrefix) && strings.HasSuffix(entry.Name(), fileSuffix) {
+			backups = append(backups, entry.Name())
+		}
+	}
+	// names contain timestamps, so sorting them sorts backups from oldest to newest
+	slices.Sort(backups)
+	return backups, nil
+}
+
+func (s *Scheduler) rotate(ctx context.Context) error {
+	backups, err := s.list()
+	if err != nil {
+		return err
+	}
+	if len(backups) <= s.keep {
+		return nil
+	}
+	for _, name := range backups[:len(backups)-s.keep] {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "backup removed", "path", filepath.Join(s.dir, name))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package backups
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestSchedulerRotate(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"backup-20240101T000000Z.bak",
+		"backup-20240102T000000Z.bak",
+		"unrelated.txt",
+	} {
+		if err := os.WriteFile(dir+"/"+name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scheduler := NewScheduler(db, nil, dir, 0, 2)
+	path, err := scheduler.Backup(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	if len(names) != 3 {
+		t.Fatalf("expected 3 files, got %v", names)
+	}
+
+	if names[0] != "backup-20240102T000000Z.bak" {
+		t.Fatalf("expected oldest backup to be removed, got %v", names)
+	}
+
+	if dir+"/"+names[1] != path {
+		t.Fatalf("expected %q to be kept, got %v", path, names)
+	}
+}
+
+func TestSchedulerRunBacksUpOnStart(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/backup-20240101T000000Z.bak", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewScheduler(db, nil, dir, time.Hour, 2).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := NewScheduler(db, nil, dir, time.Hour, 2).latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(latest) > time.Minute {
+		t.Fatalf("expected a backup to be written on start, latest is from %s", latest)
+	}
+}
+
+func TestSchedulerRunKeepsRunningOnFailure(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the backups directory can not be created under a file
+	file := t.TempDir() + "/file"
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewScheduler(db, nil, file+"/backups", time.Hour, 2).Run(ctx); err != nil {
+		t.Fatalf("expected failures to be retried, got %v", err)
+	}
+}
@@ -15,6 +15,7 @@ const (
 	BookingStatusChecked
 	BookingStatusMissed
 	BookingStatusJobScheduled
+	BookingStatusJobQueued
 )
 
 type Booking struct {
@@ -24,6 +25,10 @@ type Booking struct {
 	Position int64
 }
 
+func (b Booking) IsChecked() bool {
+	return b.Status == BookingStatusChecked
+}
+
 func (b Booking) IsMissed() bool {
 	return b.Status == BookingStatusMissed
 }
@@ -32,6 +37,10 @@ func (b Booking) IsJobScheduled() bool {
 	return b.Status == BookingStatusJobScheduled
 }
 
+func (b Booking) IsJobQueued() bool {
+	return b.Status == BookingStatusJobQueued
+}
+
 func (b Booking) IsBooked() bool {
 	return b.Status == BookingStatusBooked
 }
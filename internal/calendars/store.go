@@ -6,21 +6,21 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 type Store struct {
-	db *badger.DB
+	db kv.Store
 }
 
-func NewStore(db *badger.DB) *Store {
+func NewStore(db kv.Store) *Store {
 	return &Store{
 		db: db,
 	}
 }
 
 func (s *Store) InsertCalendar(_ context.Context, calendar *Calendar) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn kv.Txn) error {
 		data, err := json.Marshal(calendar)
 		if err != nil {
 			return err
@@ -36,16 +36,14 @@ var ErrNotFound = errors.New("not found")
 
 func (s *Store) FindByID(ctx context.Context, id string) (*Calendar, error) {
 	var calendar Calendar
-	if err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(idKey(id))
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(idKey(id))
 		if err != nil {
 			return err
 		}
-		return item.Value(func(value []byte) error {
-			return json.Unmarshal(value, &calendar)
-		})
+		return json.Unmarshal(value, &calendar)
 	}); err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
+		if errors.Is(err, kv.ErrNotFound) {
 			return nil, ErrNotFound
 		}
 		return nil, err
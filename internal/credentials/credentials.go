@@ -10,8 +10,8 @@ type Credentials struct {
 	Password string `json:"password"`
 }
 
-func (c Credentials) Encode(key *keys.Key) (*EncodedCredentials, error) {
-	encoded, err := key.Encrypt([]byte(c.Password))
+func (c Credentials) Encode(keyring *keys.Keyring) (*EncodedCredentials, error) {
+	keyID, encoded, err := keyring.Encrypt([]byte(c.Password))
 	if err != nil {
 		return nil, err
 	}
@@ -19,6 +19,7 @@ func (c Credentials) Encode(key *keys.Key) (*EncodedCredentials, error) {
 		ID:       c.ID,
 		Login:    c.Login,
 		Password: encoded,
+		KeyID:    keyID,
 	}, nil
 }
 
@@ -26,10 +27,12 @@ type EncodedCredentials struct {
 	ID       string `json:"id"`
 	Login    string `json:"login"`
 	Password []byte `json:"password"`
+	// KeyID is an id of the key password is encrypted with.
+	KeyID string `json:"key_id,omitempty"`
 }
 
-func (e EncodedCredentials) Decode(key *keys.Key) (*Credentials, error) {
-	password, err := key.Decrypt(e.Password)
+func (e EncodedCredentials) Decode(keyring *keys.Keyring) (*Credentials, error) {
+	password, err := keyring.Decrypt(e.KeyID, e.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -6,74 +6,71 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 type Store struct {
-	db            *badger.DB
-	encryptionKey *keys.Key
+	db      kv.Store
+	keyring *keys.Keyring
 }
 
 var ErrNotFound = errors.New("not found")
 
 func NewStore(
-	db *badger.DB,
-	encryptionKey *keys.Key,
+	db kv.Store,
+	keyring *keys.Keyring,
 ) *Store {
 	return &Store{
-		db:            db,
-		encryptionKey: encryptionKey,
+		db:      db,
+		keyring: keyring,
 	}
 }
 
 func (s *Store) FindByID(ctx context.Context, id string) (*Credentials, error) {
 	var credential EncodedCredentials
-	if err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(idKey(id))
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(idKey(id))
 		if err != nil {
 			return err
 		}
-		return item.Value(func(value []byte) error {
-			return json.Unmarshal(value, &credential)
-		})
+		return json.Unmarshal(value, &credential)
 	}); err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
+		if errors.Is(err, kv.ErrNotFound) {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	return credential.Decode(s.encryptionKey)
+	return credential.Decode(s.keyring)
 }
 
 func (s *Store) FindByLogin(ctx context.Context, login string) (*Credentials, error) {
 	var credential EncodedCredentials
-	if err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(loginKey(login))
+	if err := s.db.View(func(txn kv.Txn) error {
+		id, err := txn.Get(loginKey(login))
 		if err != nil {
 			return err
 		}
-		return item.Value(func(value []byte) error {
-			item, err := txn.Get(value)
-			if err != nil {
-				return err
-			}
-			return item.Value(func(value []byte) error {
-				return json.Unmarshal(value, &credential)
-			})
-		})
+		value, err := txn.Get(id)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(value, &credential)
 	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
-	return credential.Decode(s.encryptionKey)
+	return credential.Decode(s.keyring)
 }
 
 func (s *Store) Insert(ctx context.Context, credential *Credentials) error {
-	encoded, err := credential.Encode(s.encryptionKey)
+	encoded, err := credential.Encode(s.keyring)
 	if err != nil {
 		return err
 	}
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn kv.Txn) error {
 		data, err := json.Marshal(encoded)
 		if err != nil {
 			return err
@@ -88,6 +85,61 @@ func (s *Store) Insert(ctx context.Context, credential *Credentials) error {
 	})
 }
 
+// ListIDs returns ids of all credentials.
+func (s *Store) ListIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	if err := s.db.View(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("credentials/"), func(key []byte, value []byte) error {
+			var encoded EncodedCredentials
+			if err := json.Unmarshal(value, &encoded); err != nil {
+				return err
+			}
+			ids = append(ids, encoded.ID)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Reencrypt encrypts all credentials that are not encrypted with the active key with it.
+// Returns number of re-encrypted credentials.
+func (s *Store) Reencrypt(ctx context.Context) (int, error) {
+	count := 0
+	if err := s.db.Update(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("credentials/"), func(key []byte, value []byte) error {
+			var encoded EncodedCredentials
+			if err := json.Unmarshal(value, &encoded); err != nil {
+				return err
+			}
+			if encoded.KeyID == s.keyring.ActiveID() {
+				return nil
+			}
+			credential, err := encoded.Decode(s.keyring)
+			if err != nil {
+				return fmt.Errorf("decode %q: %w", encoded.ID, err)
+			}
+			reencoded, err := credential.Encode(s.keyring)
+			if err != nil {
+				return fmt.Errorf("encode %q: %w", encoded.ID, err)
+			}
+			data, err := json.Marshal(reencoded)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(key, data); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func idKey(id string) []byte {
 	return []byte(fmt.Sprintf("credentials/%s", id))
 }
@@ -4,23 +4,19 @@ import (
 	"context"
 	"testing"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 func Test(t *testing.T) {
-	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := kv.NewMemory()
 
 	key, err := keys.NewKey()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	store := NewStore(db, key)
+	store := NewStore(db, keys.NewKeyring(keys.DefaultKeyID, key))
 
 	inserted := Credentials{
 		ID:       "id",
@@ -50,4 +46,60 @@ func Test(t *testing.T) {
 	if inserted != *foundByLogin {
 		t.Fatal("inserted.ID != foundByLogin.ID")
 	}
+
+	ids, err := store.ListIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != inserted.ID {
+		t.Fatalf("expected [%s], got %v", inserted.ID, ids)
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	db := kv.NewMemory()
+
+	oldKey, err := keys.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey, err := keys.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inserted := Credentials{
+		ID:       "id",
+		Login:    "login",
+		Password: "password",
+	}
+
+	ctx := context.Background()
+	if err := NewStore(db, keys.NewKeyring("old", oldKey)).Insert(ctx, &inserted); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := keys.NewKeyring("new", newKey)
+	if err := keyring.Add("old", oldKey); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := NewStore(db, keyring).Reencrypt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Fatalf("expected 1 re-encrypted credentials, got %d", count)
+	}
+
+	found, err := NewStore(db, keys.NewKeyring("new", newKey)).FindByID(ctx, inserted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inserted != *found {
+		t.Fatal("inserted != found")
+	}
 }
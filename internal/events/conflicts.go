@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+// Conflict is a booked, reserved or scheduled event that can not be attended together with another event.
+type Conflict struct {
+	Event *Event
+	// Overlap is true if events overlap in time, otherwise there is not enough time to get between locations.
+	Overlap bool
+}
+
+// FindConflicts returns conflicts of the event with events user has booked, reserved or scheduled.
+func (s *Service) FindConflicts(ctx context.Context, event *Event) ([]Conflict, error) {
+	booked, err := s.ListBookedEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list booked events: %w", err)
+	}
+	committed, minGap, err := s.listCommittedEvents(ctx, booked)
+	if err != nil {
+		return nil, err
+	}
+	return findConflicts(event, committed, minGap), nil
+}
+
+// SetConflicts sets conflicts of each event with events user has booked, reserved or scheduled. Booked are user's
+// events as listed by ListBookedEvents, they are passed in so that pages listing them don't list them twice.
+func (s *Service) SetConflicts(ctx context.Context, events []*Event, booked []*Event) error {
+	committed, minGap, err := s.listCommittedEvents(ctx, booked)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		event.Conflicts = findConflicts(event, committed, minGap)
+	}
+	return nil
+}
+
+// listCommittedEvents returns booked, reserved and scheduled events, together with minimum gap between locations.
+// Scheduled events are taken from their jobs, events of jobs scheduled before jobs kept their details are fetched
+// and skipped if they can't be, e.g. because they were deleted.
+func (s *Service) listCommittedEvents(ctx context.Context, booked []*Event) ([]*Event, time.Duration, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return nil, 0, fmt.Errorf("token missing from context")
+	}
+	settings, err := s.settingsStore.Get(ctx, token.CredentialsID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get settings: %w", err)
+	}
+	committed := make([]*Event, 0, len(booked))
+	eventIDs := make(map[string]bool, len(booked))
+	for _, event := range booked {
+		if isCommitted(event) {
+			committed = append(committed, event)
+			eventIDs[event.ID] = true
+		}
+	}
+	scheduled, err := s.jobsStore.ListJobs(ctx,
+		jobs.ByCredentialsID(token.CredentialsID),
+		jobs.ExcludeSuccseeded(),
+		jobs.ExcludeFailed(),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list jobs: %w", err)
+	}
+	for _, job := range scheduled {
+		if job.BookEvent == nil || job.Status == jobs.StatusQueued || eventIDs[job.BookEvent.EventID] {
+			continue
+		}
+		event := eventFromJob(job.BookEvent)
+		if event == nil {
+			event, err = s.GetEvent(ctx, job.BookEvent.EventID)
+			if err != nil {
+				slog.WarnContext(ctx, "skipping scheduled event in conflicts", "event_id", job.BookEvent.EventID, "error", err)
+				continue
+			}
+		}
+		committed = append(committed, event)
+		eventIDs[event.ID] = true
+	}
+	return committed, settings.MinLocationGap, nil
+}
+
+// eventFromJob returns the event to book from details kept on the job, nil if they are not kept.
+func eventFromJob(job *jobs.BookEventJob) *Event {
+	if job.EventStartTime.IsZero() || job.EventEndTime.IsZero() {
+		return nil
+	}
+	return &Event{
+		ID:                  job.EventID,
+		DisplayName:         job.EventName,
+		LocationDisplayName: job.EventLocation,
+		StartTime:           job.EventStartTime,
+		EndTime:             job.EventEndTime,
+	}
+}
+
+func isCommitted(event *Event) bool {
+	return event.Booking != nil && (event.Booking.IsBooked() || event.Booking.IsReserved() || event.Booking.IsJobScheduled())
+}
+
+// findConflicts returns events that overlap with the event, or are at a different location less than minGap apart.
+func findConflicts(event *Event, others []*Event, minGap time.Duration) []Conflict {
+	var conflicts []Conflict
+	for _, other := range others {
+		if other.ID == event.ID {
+			continue
+		}
+		if event.StartTime.Before(other.EndTime) && other.StartTime.Before(event.EndTime) {
+			conflicts = append(conflicts, Conflict{Event: other, Overlap: true})
+			continue
+		}
+		if minGap == 0 || event.LocationDisplayName == other.LocationDisplayName {
+			continue
+		}
+		gap := event.StartTime.Sub(other.EndTime)
+		if other.StartTime.After(event.StartTime) {
+			gap = other.StartTime.Sub(event.EndTime)
+		}
+		if gap < minGap {
+			conflicts = append(conflicts, Conflict{Event: other})
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/jobs"
+)
+
+func TestFindConflicts(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, time.September, 2, hour, minute, 0, 0, time.UTC)
+	}
+	event := &Event{ID: "event", LocationDisplayName: "Odenplan", StartTime: at(10, 0), EndTime: at(10, 55)}
+
+	for name, tc := range map[string]struct {
+		other    *Event
+		overlap  bool
+		conflict bool
+	}{
+		"overlapping":                      {&Event{ID: "other", LocationDisplayName: "Odenplan", StartTime: at(10, 30), EndTime: at(11, 25)}, true, true},
+		"back to back at same location":    {&Event{ID: "other", LocationDisplayName: "Odenplan", StartTime: at(10, 55), EndTime: at(11, 50)}, false, false},
+		"back to back at another location": {&Event{ID: "other", LocationDisplayName: "Slussen", StartTime: at(11, 0), EndTime: at(11, 55)}, false, true},
+		"before at another location":       {&Event{ID: "other", LocationDisplayName: "Slussen", StartTime: at(9, 0), EndTime: at(9, 45)}, false, true},
+		"far enough at another location":   {&Event{ID: "other", LocationDisplayName: "Slussen", StartTime: at(11, 30), EndTime: at(12, 25)}, false, false},
+		"same event":                       {&Event{ID: "event", LocationDisplayName: "Odenplan", StartTime: at(10, 0), EndTime: at(10, 55)}, false, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conflicts := findConflicts(event, []*Event{tc.other}, 30*time.Minute)
+			if !tc.conflict {
+				if len(conflicts) != 0 {
+					t.Fatalf("expected no conflicts, got %+v", conflicts)
+				}
+				return
+			}
+			if len(conflicts) != 1 {
+				t.Fatalf("expected 1 conflict, got %d", len(conflicts))
+			}
+			if conflicts[0].Overlap != tc.overlap {
+				t.Fatalf("expected overlap %t, got %t", tc.overlap, conflicts[0].Overlap)
+			}
+		})
+	}
+
+	if conflicts := findConflicts(event, []*Event{{ID: "other", LocationDisplayName: "Slussen", StartTime: at(11, 0), EndTime: at(11, 55)}}, 0); len(conflicts) != 0 {
+		t.Fatalf("expected no conflicts with gap check disabled, got %+v", conflicts)
+	}
+}
+
+func TestEventFromJob(t *testing.T) {
+	start := time.Date(2024, time.September, 2, 10, 0, 0, 0, time.UTC)
+	event := eventFromJob(&jobs.BookEventJob{
+		EventID:        "event",
+		EventName:      "Reformer",
+		EventStartTime: start,
+		EventEndTime:   start.Add(55 * time.Minute),
+		EventLocation:  "Odenplan",
+	})
+	if event == nil || event.ID != "event" || event.LocationDisplayName != "Odenplan" || event.Duration() != 55*time.Minute {
+		t.Fatalf("expected event from job details, got %+v", event)
+	}
+
+	// scheduled before end time and location were kept
+	if event := eventFromJob(&jobs.BookEventJob{EventID: "event", EventName: "Reformer", EventStartTime: start}); event != nil {
+		t.Fatalf("expected no event without end time, got %+v", event)
+	}
+}
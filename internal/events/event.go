@@ -36,6 +36,16 @@ type Event struct {
 	Booking     *bookings.Booking
 	TrainerName string
 	Description string
+	// Conflicts contains booked, reserved or scheduled events that can not be attended together with the event
+	Conflicts []Conflict
+	// AlternativeOf is set if the event is booked in case another scheduled event can not be
+	AlternativeOf *Alternative
+	// AlternativeTargets are scheduled events the event can be added to as an alternative
+	AlternativeTargets []*Event
+	// UsuallyFullWithin is how fast similar events usually sell out after opening, zero if unknown
+	UsuallyFullWithin time.Duration
+	// PromotionChance is an estimated chance, in percent, to get a place from the reserve list, nil if unknown
+	PromotionChance *int
 
 	PlacesTotal   int64
 	PlacesTaken   int64
@@ -43,6 +53,15 @@ type Event struct {
 	ReservesTaken int64
 }
 
+// Alternative refers to a booking job the event is an alternative of.
+type Alternative struct {
+	JobID     string
+	EventID   string
+	EventName string
+	// Rank is a position of the event among the alternatives, starting at 1.
+	Rank int
+}
+
 func (e Event) Duration() time.Duration {
 	return e.EndTime.Sub(e.StartTime)
 }
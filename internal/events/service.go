@@ -4,25 +4,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/pilatescomplete-bot/internal/bookings"
 	"github.com/pilatescomplete-bot/internal/jobs"
 	"github.com/pilatescomplete-bot/internal/pilatescomplete"
+	"github.com/pilatescomplete-bot/internal/settings"
 	"github.com/pilatescomplete-bot/internal/tokens"
 )
 
 type Service struct {
-	apiClient *pilatescomplete.APIClient
-	jobsStore *jobs.Store
+	apiClient     *pilatescomplete.APIClient
+	jobsStore     *jobs.Store
+	settingsStore *settings.Store
 }
 
 func NewService(
 	jobsStore *jobs.Store,
+	settingsStore *settings.Store,
 	apiClient *pilatescomplete.APIClient,
 ) *Service {
 	return &Service{
-		jobsStore: jobsStore,
-		apiClient: apiClient,
+		jobsStore:     jobsStore,
+		settingsStore: settingsStore,
+		apiClient:     apiClient,
 	}
 }
 
@@ -64,11 +69,11 @@ func (s *Service) listEvents(ctx context.Context, input pilatescomplete.ListEven
 	if err != nil {
 		return nil, fmt.Errorf("events from api: %w", err)
 	}
-	eventIDs := make([]string, 0, len(events))
 	eventsByID := make(map[string]*Event, len(events))
+	eventIDs := make([]string, 0, len(events))
 	for _, event := range events {
-		eventIDs = append(eventIDs, event.ID)
 		eventsByID[event.ID] = event
+		eventIDs = append(eventIDs, event.ID)
 	}
 	bookingJobs, err := s.jobsStore.ListJobs(ctx,
 		jobs.BookEventsByCredentialsIDEventIDs(token.CredentialsID, eventIDs...),
@@ -79,10 +84,49 @@ func (s *Service) listEvents(ctx context.Context, input pilatescomplete.ListEven
 		return nil, fmt.Errorf("list jobs: %w", err)
 	}
 	for _, job := range bookingJobs {
-		eventsByID[job.BookEvent.EventID].Booking = &bookings.Booking{
-			ID:     job.ID,
-			Status: bookings.BookingStatusJobScheduled,
+		if event, ok := eventsByID[job.BookEvent.EventID]; ok {
+			status := bookings.BookingStatus(bookings.BookingStatusJobScheduled)
+			if job.Status == jobs.StatusQueued {
+				status = bookings.BookingStatusJobQueued
+			}
+			event.Booking = &bookings.Booking{
+				ID:     job.ID,
+				Status: status,
+			}
+		}
+	}
+	for _, job := range bookingJobs {
+		for i, eventID := range job.BookEvent.Alternatives {
+			event, ok := eventsByID[eventID]
+			if !ok || event.Booking != nil {
+				continue
+			}
+			event.AlternativeOf = &Alternative{
+				JobID:     job.ID,
+				EventID:   job.BookEvent.EventID,
+				EventName: job.BookEvent.EventName,
+				Rank:      i + 1,
+			}
 		}
 	}
 	return events, nil
 }
+
+// SetAlternativeTargets sets scheduled events on the same day as alternative targets of events that are not
+// booked yet.
+func (s *Service) SetAlternativeTargets(events []*Event) {
+	for _, event := range events {
+		if event.Booking != nil || event.AlternativeOf != nil {
+			continue
+		}
+		for _, target := range events {
+			if target.Booking == nil || !(target.Booking.IsJobScheduled() || target.Booking.IsJobQueued()) {
+				continue
+			}
+			if target.ID == event.ID || target.StartTime.Format(time.DateOnly) != event.StartTime.Format(time.DateOnly) {
+				continue
+			}
+			event.AlternativeTargets = append(event.AlternativeTargets, target)
+		}
+	}
+}
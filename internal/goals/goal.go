@@ -0,0 +1,106 @@
+package goals
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+type Period string
+
+const (
+	PeriodWeek Period = "week"
+	PeriodYear Period = "year"
+)
+
+var ErrUnknownPeriod = errors.New("unknown period")
+
+func ParsePeriod(value string) (Period, error) {
+	switch period := Period(value); period {
+	case PeriodWeek, PeriodYear:
+		return period, nil
+	default:
+		return "", fmt.Errorf("%q: %w", value, ErrUnknownPeriod)
+	}
+}
+
+// Bounds returns start and end of the period that contains the time, in the time's location. Periods start at
+// midnight in Stockholm, so the time should be in Stockholm.
+func (p Period) Bounds(t time.Time) (time.Time, time.Time) {
+	switch p {
+	case PeriodWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		start := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 0, 7)
+	default:
+		start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(1, 0, 0)
+	}
+}
+
+// Goal is a target number of attended classes per period.
+type Goal struct {
+	ID            string    `json:"id"`
+	CredentialsID string    `json:"credentials_id"`
+	Period        Period    `json:"period"`
+	Target        int       `json:"target"`
+	CreatedAt     time.Time `json:"created_at"`
+	// NudgeCheckedAt is a time when the goal was last checked for a nudge. Goals are checked at most once a period.
+	NudgeCheckedAt time.Time `json:"nudge_checked_at,omitempty"`
+}
+
+func NewGoal(credentialsID string, period Period, target int) *Goal {
+	return &Goal{
+		ID:            gonanoid.Must(),
+		CredentialsID: credentialsID,
+		Period:        period,
+		Target:        target,
+		CreatedAt:     time.Now(),
+	}
+}
+
+// Progress is progress towards a goal in the current period.
+type Progress struct {
+	Goal *Goal
+	// Done is a number of classes attended in the period so far.
+	Done  int
+	Start time.Time
+	End   time.Time
+	// Elapsed is a share of the period that has passed, from 0 to 1.
+	Elapsed float64
+}
+
+func newProgress(goal *Goal, done int, now time.Time) *Progress {
+	start, end := goal.Period.Bounds(now)
+	return &Progress{
+		Goal:    goal,
+		Done:    done,
+		Start:   start,
+		End:     end,
+		Elapsed: float64(now.Sub(start)) / float64(end.Sub(start)),
+	}
+}
+
+// Percent returns progress towards the target, in percent capped at 100.
+func (p *Progress) Percent() int {
+	if p.Goal.Target <= 0 {
+		return 100
+	}
+	return min(100, p.Done*100/p.Goal.Target)
+}
+
+// Expected returns a number of classes that should be attended by now to reach the target evenly.
+func (p *Progress) Expected() int {
+	return int(math.Floor(float64(p.Goal.Target) * p.Elapsed))
+}
+
+func (p *Progress) IsReached() bool {
+	return p.Done >= p.Goal.Target
+}
+
+func (p *Progress) IsBehind() bool {
+	return p.Done < p.Expected()
+}
@@ -0,0 +1,71 @@
+package goals
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/timezone"
+)
+
+func TestProgress(t *testing.T) {
+	// Thursday noon, the week is half over
+	now := time.Date(2025, time.March, 6, 12, 0, 0, 0, time.UTC)
+
+	weekly := NewGoal("a", PeriodWeek, 3)
+	start, end := PeriodWeek.Bounds(now)
+	if expected := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC); !start.Equal(expected) || !end.Equal(expected.AddDate(0, 0, 7)) {
+		t.Fatalf("expected week from %s, got %s - %s", expected, start, end)
+	}
+
+	progress := newProgress(weekly, 0, now)
+	if progress.Expected() != 1 || !progress.IsBehind() {
+		t.Fatalf("expected to be behind 1 class, got %+v", progress)
+	}
+	progress = newProgress(weekly, 1, now)
+	if progress.IsBehind() || progress.Percent() != 33 {
+		t.Fatalf("expected to be on track at 33%%, got %+v", progress)
+	}
+	progress = newProgress(weekly, 4, now)
+	if !progress.IsReached() || progress.Percent() != 100 {
+		t.Fatalf("expected to be reached at 100%%, got %+v", progress)
+	}
+
+	yearly := NewGoal("a", PeriodYear, 100)
+	progress = newProgress(yearly, 10, now)
+	if progress.Expected() != 17 || !progress.IsBehind() {
+		t.Fatalf("expected to be behind 17 classes, got %+v", progress)
+	}
+}
+
+func TestBoundsInStockholm(t *testing.T) {
+	// Sunday evening in UTC is Monday past midnight in Stockholm
+	now := timezone.ToStockholm(time.Date(2025, time.March, 9, 23, 30, 0, 0, time.UTC))
+
+	start, _ := PeriodWeek.Bounds(now)
+	if expected := timezone.InStockholm(time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)); !start.Equal(expected) {
+		t.Fatalf("expected week from %s, got %s", expected, start)
+	}
+}
+
+func TestShouldCheckNudge(t *testing.T) {
+	wednesday := time.Date(2025, time.March, 5, 12, 0, 0, 0, time.UTC)
+	thursday := wednesday.AddDate(0, 0, 1)
+
+	weekly := NewGoal("a", PeriodWeek, 3)
+	if shouldCheckNudge(weekly, wednesday) {
+		t.Fatal("expected no check before the week is half over")
+	}
+	if !shouldCheckNudge(weekly, thursday) {
+		t.Fatal("expected check when the week is half over")
+	}
+	weekly.NudgeCheckedAt = thursday
+	if shouldCheckNudge(weekly, thursday.Add(time.Hour)) {
+		t.Fatal("expected one check a week")
+	}
+	if !shouldCheckNudge(weekly, thursday.AddDate(0, 0, 7)) {
+		t.Fatal("expected check next week")
+	}
+	if shouldCheckNudge(NewGoal("a", PeriodYear, 100), thursday) {
+		t.Fatal("expected yearly goals to not be nudged")
+	}
+}
@@ -0,0 +1,148 @@
+package goals
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/authentication"
+	"github.com/pilatescomplete-bot/internal/credentials"
+	"github.com/pilatescomplete-bot/internal/statistics"
+	"github.com/pilatescomplete-bot/internal/timezone"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+type Service struct {
+	store                 *Store
+	statisticsService     *statistics.Service
+	authenticationService *authentication.Service
+	credentialsStore      *credentials.Store
+
+	behindCallbacks []func(context.Context, *Progress)
+}
+
+func NewService(
+	store *Store,
+	statisticsService *statistics.Service,
+	authenticationService *authentication.Service,
+	credentialsStore *credentials.Store,
+) *Service {
+	return &Service{
+		store:                 store,
+		statisticsService:     statisticsService,
+		authenticationService: authenticationService,
+		credentialsStore:      credentialsStore,
+	}
+}
+
+// OnBehind registers a callback that is called when a weekly goal is behind halfway through the week.
+func (s *Service) OnBehind(fn func(context.Context, *Progress)) {
+	s.behindCallbacks = append(s.behindCallbacks, fn)
+}
+
+// ListProgress returns progress of the authenticated user's goals in current periods.
+func (s *Service) ListProgress(ctx context.Context) ([]*Progress, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("token missing from context")
+	}
+	goals, err := s.store.ListByCredentialsID(ctx, token.CredentialsID)
+	if err != nil {
+		return nil, fmt.Errorf("list goals: %w", err)
+	}
+	now := timezone.ToStockholm(time.Now())
+	progress := make([]*Progress, 0, len(goals))
+	for _, goal := range goals {
+		p, err := s.progress(ctx, goal, now)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
+
+func (s *Service) progress(ctx context.Context, goal *Goal, now time.Time) (*Progress, error) {
+	start, end := goal.Period.Bounds(now)
+	done, err := s.statisticsService.CountAttended(ctx, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("count attended: %w", err)
+	}
+	return newProgress(goal, done, now), nil
+}
+
+// Run checks weekly goals of all credentials every interval, until context is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.nudgeAll(ctx)
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "stopping goals nudges")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) nudgeAll(ctx context.Context) {
+	credentialsIDs, err := s.credentialsStore.ListIDs(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "list credentials", "error", err)
+		return
+	}
+	now := timezone.ToStockholm(time.Now())
+	for _, credentialsID := range credentialsIDs {
+		if err := s.nudge(ctx, credentialsID, now); err != nil {
+			slog.ErrorContext(ctx, "nudge goals", "credentials_id", credentialsID, "error", err)
+		}
+	}
+}
+
+func (s *Service) nudge(ctx context.Context, credentialsID string, now time.Time) error {
+	goals, err := s.store.ListByCredentialsID(ctx, credentialsID)
+	if err != nil {
+		return fmt.Errorf("list goals: %w", err)
+	}
+	authenticated := false
+	for _, goal := range goals {
+		if !shouldCheckNudge(goal, now) {
+			continue
+		}
+		if !authenticated {
+			ctx, err = s.authenticationService.AuthenticateContext(ctx, credentialsID)
+			if err != nil {
+				return fmt.Errorf("authenticate context: %w", err)
+			}
+			authenticated = true
+		}
+		progress, err := s.progress(ctx, goal, now)
+		if err != nil {
+			return err
+		}
+		if progress.IsBehind() {
+			for _, fn := range s.behindCallbacks {
+				fn(ctx, progress)
+			}
+		}
+		goal.NudgeCheckedAt = now
+		if err := s.store.Insert(ctx, goal); err != nil {
+			return fmt.Errorf("insert goal: %w", err)
+		}
+	}
+	return nil
+}
+
+// shouldCheckNudge returns true if the goal is weekly, the week is half over and the goal was not checked this week.
+func shouldCheckNudge(goal *Goal, now time.Time) bool {
+	if goal.Period != PeriodWeek {
+		return false
+	}
+	start, end := goal.Period.Bounds(now)
+	if now.Before(start.Add(end.Sub(start) / 2)) {
+		return false
+	}
+	return goal.NudgeCheckedAt.Before(start)
+}
@@ -0,0 +1,77 @@
+package goals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type Store struct {
+	db kv.Store
+}
+
+func NewStore(db kv.Store) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Insert(_ context.Context, goal *Goal) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		data, err := json.Marshal(goal)
+		if err != nil {
+			return err
+		}
+		return txn.Set(goalKey(goal.CredentialsID, goal.ID), data)
+	})
+}
+
+func (s *Store) Delete(_ context.Context, credentialsID string, id string) error {
+	if err := s.db.Update(func(txn kv.Txn) error {
+		if _, err := txn.Get(goalKey(credentialsID, id)); err != nil {
+			return err
+		}
+		return txn.Delete(goalKey(credentialsID, id))
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListByCredentialsID returns goals of the credentials id, oldest first.
+func (s *Store) ListByCredentialsID(_ context.Context, credentialsID string) ([]*Goal, error) {
+	var goals []*Goal
+	if err := s.db.View(func(txn kv.Txn) error {
+		return txn.Iterate(goalsPrefix(credentialsID), func(_ []byte, value []byte) error {
+			goal := &Goal{}
+			if err := json.Unmarshal(value, goal); err != nil {
+				return err
+			}
+			goals = append(goals, goal)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(goals, func(a, b *Goal) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return goals, nil
+}
+
+func goalsPrefix(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("goals/%s/", credentialsID))
+}
+
+func goalKey(credentialsID string, id string) []byte {
+	return append(goalsPrefix(credentialsID), id...)
+}
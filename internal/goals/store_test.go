@@ -0,0 +1,46 @@
+package goals
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	weekly := NewGoal("a", PeriodWeek, 3)
+	yearly := NewGoal("a", PeriodYear, 100)
+	yearly.CreatedAt = weekly.CreatedAt.Add(time.Second)
+	for _, goal := range []*Goal{yearly, weekly, NewGoal("b", PeriodWeek, 1)} {
+		if err := store.Insert(ctx, goal); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	goals, err := store.ListByCredentialsID(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 2 || goals[0].ID != weekly.ID || goals[1].ID != yearly.ID {
+		t.Fatalf("expected weekly and yearly goals, got %+v", goals)
+	}
+
+	if err := store.Delete(ctx, "b", weekly.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected %v deleting goal of other credentials, got %v", ErrNotFound, err)
+	}
+	if err := store.Delete(ctx, "a", weekly.ID); err != nil {
+		t.Fatal(err)
+	}
+	goals, err = store.ListByCredentialsID(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 1 || goals[0].ID != yearly.ID {
+		t.Fatalf("expected yearly goal, got %+v", goals)
+	}
+}
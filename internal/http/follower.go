@@ -0,0 +1,51 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/pilatescomplete-bot/internal/leases"
+)
+
+// FollowerHandler serves instances that do not hold the lease. Such instances have no access to the database and
+// are read-only, so reads are forwarded to the lease holder, or rejected if its address is unknown, and writes are
+// always rejected.
+func FollowerHandler(lease *leases.Lease) http.HandlerFunc {
+	unavailable := func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			unavailable(w)
+			return
+		}
+		record, err := lease.Current()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "current lease", "error", err)
+			unavailable(w)
+			return
+		}
+		if record == nil || record.Address == "" {
+			unavailable(w)
+			return
+		}
+		target, err := url.Parse(record.Address)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "parse leader address", "address", record.Address, "error", err)
+			unavailable(w)
+			return
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.ErrorContext(r.Context(), "forward to leader", "address", record.Address, "error", err)
+			unavailable(w)
+		}
+		proxy.ServeHTTP(w, r)
+	}
+	return WithAccessLogs()(handler)
+}
@@ -2,16 +2,17 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/pilatescomplete-bot/internal/authentication"
 	"github.com/pilatescomplete-bot/internal/bookings"
@@ -19,10 +20,17 @@ import (
 	"github.com/pilatescomplete-bot/internal/credentials"
 	"github.com/pilatescomplete-bot/internal/devices"
 	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/goals"
 	"github.com/pilatescomplete-bot/internal/http/templates"
 	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/notifications"
 	"github.com/pilatescomplete-bot/internal/pilatescomplete"
+	"github.com/pilatescomplete-bot/internal/planner"
+	"github.com/pilatescomplete-bot/internal/settings"
 	"github.com/pilatescomplete-bot/internal/statistics"
+	"github.com/pilatescomplete-bot/internal/telegram"
+	"github.com/pilatescomplete-bot/internal/timetable"
+	"github.com/pilatescomplete-bot/internal/timezone"
 	"github.com/pilatescomplete-bot/internal/tokens"
 )
 
@@ -32,29 +40,57 @@ func Handler(
 	apiClient *pilatescomplete.APIClient,
 	tokensStore *tokens.Store,
 	credentialsStore *credentials.Store,
+	settingsStore *settings.Store,
 	authenticationService *authentication.Service,
 	eventsService *events.Service,
 	scheduler *jobs.Scheduler,
+	planner *planner.Planner,
 	calendarsService *calendars.Service,
 	statisticsService *statistics.Service,
+	goalsStore *goals.Store,
+	goalsService *goals.Service,
+	timetableService *timetable.Service,
+	notificationsService *notifications.Service,
+	telegramBot *telegram.Bot,
+	clockSkewThreshold time.Duration,
 ) http.HandlerFunc {
 	requireAuth := WithAuthentication(authenticationService, credentialsStore)
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /{$}", requireAuth(redirectTo("/schedule/")))
-	mux.HandleFunc("GET /schedule/{$}", requireAuth(handleScheduleEvents(renderer, eventsService)))
-	mux.HandleFunc("GET /book/{$}", requireAuth(handleBookEvents(renderer, eventsService)))
+	mux.HandleFunc("GET /schedule/{$}", requireAuth(handleScheduleEvents(renderer, eventsService, timetableService)))
+	mux.HandleFunc("GET /book/{$}", requireAuth(handleBookEvents(renderer, eventsService, timetableService)))
+	mux.HandleFunc("GET /timetable/sellouts.json", requireAuth(handleSellOutsJSON(timetableService)))
 	mux.HandleFunc("GET /statistics/year/{year}/{$}", requireAuth(handleYearStatistics(renderer, statisticsService)))
+	mux.HandleFunc("GET /statistics/year/{year}/metrics.json", requireAuth(handleYearMetricsJSON(statisticsService)))
+	mux.HandleFunc("GET /statistics/year/{year}/review/{$}", requireAuth(handleYearReview(renderer, statisticsService)))
+	mux.HandleFunc("GET /statistics/year/{year}/review.svg", requireAuth(handleYearReviewCard(renderer, statisticsService)))
+	mux.HandleFunc("GET /statistics/export/{filename}", requireAuth(handleExportStatistics(statisticsService)))
 	mux.HandleFunc("GET /statistics/year/{year}/month/{month}/{$}", requireAuth(handleYearMonthStatistics(renderer, statisticsService)))
 	mux.HandleFunc("GET /statistics/year/{year}/week/{week}/{$}", requireAuth(handleYearWeekStatistics(renderer, statisticsService)))
-	mux.HandleFunc("GET /statistics/{$}", requireAuth(handleStatistics()))
+	mux.HandleFunc("GET /statistics/{$}", requireAuth(handleStatistics(renderer, goalsService, telegramBot)))
+	mux.HandleFunc("POST /statistics/goals", requireAuth(handleCreateGoal(goalsStore)))
+	mux.HandleFunc("DELETE /statistics/goals/{goal_id}", requireAuth(handleDeleteGoal(goalsStore)))
+	mux.HandleFunc("POST /statistics/goals/telegram", requireAuth(handleLinkTelegram(telegramBot)))
+	mux.HandleFunc("GET /jobs/{$}", requireAuth(handleJobs(renderer, scheduler)))
+	mux.HandleFunc("GET /jobs.json", requireAuth(handleJobsJSON(scheduler)))
+	mux.HandleFunc("POST /jobs/{job_id}/priority", requireAuth(handleMoveQueuedJob(scheduler)))
+	mux.HandleFunc("GET /notifications/{$}", requireAuth(handleNotifications(renderer, notificationsService)))
+	mux.HandleFunc("GET /notifications/badge", requireAuth(handleNotificationsBadge(renderer, notificationsService)))
+	mux.HandleFunc("POST /notifications/read", requireAuth(handleMarkAllNotificationsRead(notificationsService)))
+	mux.HandleFunc("POST /notifications/{notification_id}/read", requireAuth(handleMarkNotificationRead(notificationsService)))
+	mux.HandleFunc("GET /settings/{$}", requireAuth(handleSettings(renderer, settingsStore)))
+	mux.HandleFunc("POST /settings/{$}", requireAuth(handleUpdateSettings(settingsStore)))
+	mux.HandleFunc("GET /health/{$}", requireAuth(handleHealth(renderer, apiClient, clockSkewThreshold)))
 	mux.HandleFunc("POST /{$}", handleLogin(apiClient, credentialsStore, tokensStore))
 
 	mux.HandleFunc("GET /login", handleAuthenticationPage(renderer))
 
-	mux.HandleFunc("POST /events/{event_id}/bookings", requireAuth(handleCreateBooking(renderer, apiClient, eventsService, scheduler)))
-	mux.HandleFunc("DELETE /events/{event_id}/bookings/{booking_id}", requireAuth(handleDeleteBooking(renderer, eventsService, apiClient)))
+	mux.HandleFunc("POST /events/{event_id}/bookings", requireAuth(handleCreateBooking(renderer, apiClient, eventsService, scheduler, planner)))
+	mux.HandleFunc("DELETE /events/{event_id}/bookings/{booking_id}", requireAuth(handleDeleteBooking(renderer, eventsService, apiClient, planner)))
 
 	mux.HandleFunc("DELETE /events/{event_id}/jobs/{job_id}", requireAuth(handleDeleteJob(renderer, eventsService, scheduler)))
+	mux.HandleFunc("POST /events/{event_id}/jobs/{job_id}/alternatives", requireAuth(handleAddAlternative(renderer, eventsService, scheduler, settingsStore)))
+	mux.HandleFunc("DELETE /events/{event_id}/jobs/{job_id}/alternatives/{alternative_id}", requireAuth(handleRemoveAlternative(renderer, eventsService, scheduler)))
 
 	mux.HandleFunc("GET /calendars/{calendar_id}/pilatescomplete.ics", handleGetCalendar(calendarsService))
 	mux.HandleFunc("POST /calendars", requireAuth(handleCreateCalendar(calendarsService)))
@@ -148,10 +184,126 @@ func handleDeleteJob(
 	}
 }
 
+func handleAddAlternative(
+	renderer templates.Renderer,
+	eventsService *events.Service,
+	scheduler *jobs.Scheduler,
+	settingsStore *settings.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		eventID := parts[2]
+		jobID := parts[4]
+
+		if err := r.ParseForm(); err != nil {
+			slog.ErrorContext(r.Context(), "parse form", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		alternativeID := r.PostForm.Get("alternative_id")
+		if alternativeID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		job, err := scheduler.FindByID(r.Context(), jobID)
+		if errors.Is(err, jobs.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "find job by id", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if job.BookEvent == nil || job.BookEvent.EventID != eventID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		settings, err := settingsStore.Get(r.Context(), job.BookEvent.CredentialsID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get settings", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err := scheduler.AddAlternative(r.Context(), jobID, alternativeID, settings.ReserveFirstChoice); errors.Is(err, jobs.ErrJobRunning) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "add alternative", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		alternative, err := eventsService.GetEvent(r.Context(), alternativeID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get event", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := renderer.RenderEvent(w, alternative); err != nil {
+			slog.ErrorContext(r.Context(), "render event", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleRemoveAlternative(
+	renderer templates.Renderer,
+	eventsService *events.Service,
+	scheduler *jobs.Scheduler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		eventID := parts[2]
+		jobID := parts[4]
+		alternativeID := parts[6]
+
+		job, err := scheduler.FindByID(r.Context(), jobID)
+		if errors.Is(err, jobs.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "find job by id", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if job.BookEvent == nil || job.BookEvent.EventID != eventID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if _, err := scheduler.RemoveAlternative(r.Context(), jobID, alternativeID); errors.Is(err, jobs.ErrJobRunning) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "remove alternative", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		alternative, err := eventsService.GetEvent(r.Context(), alternativeID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get event", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := renderer.RenderEvent(w, alternative); err != nil {
+			slog.ErrorContext(r.Context(), "render event", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
 func handleDeleteBooking(
 	renderer templates.Renderer,
 	eventsService *events.Service,
 	apiClient *pilatescomplete.APIClient,
+	planner *planner.Planner,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		parts := strings.Split(r.URL.Path, "/")
@@ -164,6 +316,12 @@ func handleDeleteBooking(
 			return
 		}
 
+		// cancelled booking might have made room for a queued one
+		token, _ := tokens.FromContext(r.Context())
+		if _, err := planner.Release(r.Context(), token.CredentialsID); err != nil {
+			slog.ErrorContext(r.Context(), "release queued jobs", "error", err)
+		}
+
 		event, err := eventsService.GetEvent(r.Context(), eventID)
 		if err != nil {
 			slog.ErrorContext(r.Context(), "get event", "error", err)
@@ -185,6 +343,7 @@ func handleCreateBooking(
 	apiClient *pilatescomplete.APIClient,
 	eventsService *events.Service,
 	scheduler *jobs.Scheduler,
+	planner *planner.Planner,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		parts := strings.Split(r.URL.Path, "/")
@@ -196,7 +355,31 @@ func handleCreateBooking(
 			return
 		}
 
-		event, err := bookOrScheduleEventBooking(r.Context(), eventID, eventsService, scheduler, apiClient)
+		if r.PostForm.Get("override") != "1" {
+			event, err := eventsService.GetEvent(r.Context(), eventID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "get event", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			conflicts, err := eventsService.FindConflicts(r.Context(), event)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "find conflicts", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if len(conflicts) > 0 {
+				// render a warning, booking is made once it's submitted again with override
+				event.Conflicts = conflicts
+				if err := renderer.RenderEvent(w, event); err != nil {
+					slog.ErrorContext(r.Context(), "render event", "error", err)
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+
+		event, err := bookOrScheduleEventBooking(r.Context(), eventID, eventsService, scheduler, planner, apiClient)
 		if err != nil {
 			slog.ErrorContext(r.Context(), "book or schedule event booking", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -216,6 +399,7 @@ func bookOrScheduleEventBooking(
 	eventID string,
 	eventsService *events.Service,
 	scheduler *jobs.Scheduler,
+	planner *planner.Planner,
 	apiClient *pilatescomplete.APIClient,
 ) (*events.Event, error) {
 	if _, err := apiClient.BookActivity(ctx, eventID); err != nil {
@@ -226,6 +410,13 @@ func bookOrScheduleEventBooking(
 			}
 			return event, nil
 		}
+		if errors.Is(err, pilatescomplete.ErrOverbooked) || errors.Is(err, pilatescomplete.ErrAccessNotAllowed) {
+			event, err := queueEventBooking(ctx, eventID, pilatescomplete.ErrorCode(err), eventsService, scheduler, planner)
+			if err != nil {
+				return nil, fmt.Errorf("queue event booking: %w", err)
+			}
+			return event, nil
+		}
 		return nil, fmt.Errorf("book activity: %w", err)
 	}
 
@@ -248,11 +439,12 @@ func scheduleEventBooking(
 		return nil, fmt.Errorf("get event: %w", err)
 	}
 
-	job, err := jobs.NewBookEventJob(ctx, eventID, event.BookableFrom)
+	job, err := jobs.NewBookEventJob(ctx, eventID, event.DisplayName, event.StartTime, event.EndTime, event.LocationDisplayName, event.BookableFrom)
 	if err != nil {
 		return nil, fmt.Errorf("new book event job: %w", err)
 	}
-	if err := scheduler.Schedule(ctx, job); err != nil {
+	job, err = scheduler.Schedule(ctx, job)
+	if err != nil {
 		return nil, fmt.Errorf("schedule: %w", err)
 	}
 
@@ -264,6 +456,230 @@ func scheduleEventBooking(
 	return event, nil
 }
 
+// queueEventBooking queues booking of the event, because user is at their limit. The booking is made
+// once the planner releases it.
+func queueEventBooking(
+	ctx context.Context,
+	eventID string,
+	errorCode string,
+	eventsService *events.Service,
+	scheduler *jobs.Scheduler,
+	planner *planner.Planner,
+) (*events.Event, error) {
+	event, err := eventsService.GetEvent(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("get event: %w", err)
+	}
+
+	job, err := jobs.NewBookEventJob(ctx, eventID, event.DisplayName, event.StartTime, event.EndTime, event.LocationDisplayName, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("new book event job: %w", err)
+	}
+	if err := planner.Learn(ctx, job.BookEvent.CredentialsID, event.StartTime, errorCode); err != nil {
+		slog.ErrorContext(ctx, "learn booking limits", "error", err)
+	}
+	job, err = scheduler.Queue(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("queue: %w", err)
+	}
+
+	status := bookings.BookingStatus(bookings.BookingStatusJobQueued)
+	if job.Status != jobs.StatusQueued {
+		status = bookings.BookingStatusJobScheduled
+	}
+	event.Booking = &bookings.Booking{
+		ID:     job.ID,
+		Status: status,
+	}
+
+	return event, nil
+}
+
+func handleMoveQueuedJob(scheduler *jobs.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		jobID := parts[2]
+
+		if err := r.ParseForm(); err != nil {
+			slog.ErrorContext(r.Context(), "parse form", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		direction := r.PostForm.Get("direction")
+		if direction != "up" && direction != "down" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := scheduler.MoveQueuedJob(r.Context(), jobID, direction == "up"); errors.Is(err, jobs.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "move queued job", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/jobs/", http.StatusFound)
+	}
+}
+
+func handleJobs(
+	renderer templates.Renderer,
+	scheduler *jobs.Scheduler,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := scheduler.ListJobs(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list jobs", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		history, err := scheduler.ListHistory(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list history", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		token, _ := tokens.FromContext(r.Context())
+		queue, err := scheduler.ListQueuedJobs(r.Context(), token.CredentialsID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list queued jobs", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := renderer.RenderJobsPage(w, templates.JobsData{
+			Jobs:    jobs,
+			Queue:   queue,
+			History: history,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "render jobs page", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleJobsJSON(scheduler *jobs.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduled, err := scheduler.ListJobs(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list jobs", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		history, err := scheduler.ListHistory(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list history", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Jobs    []*jobs.Job          `json:"jobs"`
+			History []*jobs.HistoryEntry `json:"history"`
+		}{
+			Jobs:    scheduled,
+			History: history,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "encode jobs", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleSettings(
+	renderer templates.Renderer,
+	settingsStore *settings.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := tokens.FromContext(r.Context())
+		settings, err := settingsStore.Get(r.Context(), token.CredentialsID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get settings", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := renderer.RenderSettingsPage(w, templates.SettingsData{
+			MinLocationGapMinutes: int(settings.MinLocationGap / time.Minute),
+			MaxBookings:           settings.MaxBookings,
+			MaxDailyBookings:      settings.MaxDailyBookings,
+			ReserveFirstChoice:    settings.ReserveFirstChoice,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "render settings page", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleUpdateSettings(settingsStore *settings.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			slog.ErrorContext(r.Context(), "parse form", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		minutes, err := strconv.Atoi(r.PostForm.Get("min_location_gap"))
+		if err != nil || minutes < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		maxBookings, err := strconv.Atoi(r.PostForm.Get("max_bookings"))
+		if err != nil || maxBookings < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		maxDailyBookings, err := strconv.Atoi(r.PostForm.Get("max_daily_bookings"))
+		if err != nil || maxDailyBookings < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token, _ := tokens.FromContext(r.Context())
+		settings, err := settingsStore.Get(r.Context(), token.CredentialsID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get settings", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		settings.MinLocationGap = time.Duration(minutes) * time.Minute
+		settings.MaxBookings = maxBookings
+		settings.MaxDailyBookings = maxDailyBookings
+		settings.ReserveFirstChoice = r.PostForm.Get("reserve_first_choice") == "1"
+		if err := settingsStore.Insert(r.Context(), settings); err != nil {
+			slog.ErrorContext(r.Context(), "insert settings", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/settings/", http.StatusFound)
+	}
+}
+
+func handleHealth(
+	renderer templates.Renderer,
+	apiClient *pilatescomplete.APIClient,
+	clockSkewThreshold time.Duration,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		skew := apiClient.Clock().Skew()
+		now := time.Now()
+		if err := renderer.RenderHealthPage(w, templates.HealthData{
+			Skew:          skew,
+			SkewThreshold: clockSkewThreshold,
+			SkewExceeded:  skew.Offset.Abs() > clockSkewThreshold,
+			LocalTime:     now,
+			ServerTime:    now.Add(skew.Offset),
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "render health page", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
 func handleAuthenticationPage(renderer templates.Renderer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := renderer.RenderLoginPage(w, templates.LoginData{}); err != nil {
@@ -274,9 +690,173 @@ func handleAuthenticationPage(renderer templates.Renderer) http.HandlerFunc {
 	}
 }
 
-func handleStatistics() http.HandlerFunc {
+func handleStatistics(
+	renderer templates.Renderer,
+	goalsService *goals.Service,
+	telegramBot *telegram.Bot,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		progress, err := goalsService.ListProgress(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list goals progress", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		year, week := now.ISOWeek()
+		if err := renderer.RenderStatisticsPage(w, templates.StatisticsData{
+			Year:     now.Year(),
+			Month:    int(now.Month()),
+			WeekYear: year,
+			Week:     week,
+			Goals:    progress,
+			Telegram: telegramBot != nil,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "render statistics page", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleCreateGoal(goalsStore *goals.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			slog.ErrorContext(r.Context(), "parse form", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		period, err := goals.ParsePeriod(r.PostForm.Get("period"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		target, err := strconv.Atoi(r.PostForm.Get("target"))
+		if err != nil || target <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token, _ := tokens.FromContext(r.Context())
+		if err := goalsStore.Insert(r.Context(), goals.NewGoal(token.CredentialsID, period, target)); err != nil {
+			slog.ErrorContext(r.Context(), "insert goal", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/statistics/", http.StatusFound)
+	}
+}
+
+func handleDeleteGoal(goalsStore *goals.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		id := parts[3]
+
+		token, _ := tokens.FromContext(r.Context())
+		if err := goalsStore.Delete(r.Context(), token.CredentialsID, id); errors.Is(err, goals.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "delete goal", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleLinkTelegram redirects to a chat with the telegram bot, that is linked to the user once started.
+func handleLinkTelegram(telegramBot *telegram.Bot) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, fmt.Sprintf("/statistics/year/%d/", time.Now().Year()), http.StatusTemporaryRedirect)
+		if telegramBot == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		token, _ := tokens.FromContext(r.Context())
+		link, err := telegramBot.NewLink(r.Context(), token.CredentialsID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "new telegram link", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, link, http.StatusSeeOther)
+	}
+}
+
+func handleNotifications(
+	renderer templates.Renderer,
+	notificationsService *notifications.Service,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := notificationsService.ListInbox(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list inbox", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		unread := 0
+		for _, entry := range entries {
+			if !entry.Read {
+				unread++
+			}
+		}
+		if err := renderer.RenderNotificationsPage(w, templates.NotificationsData{
+			Entries: entries,
+			Unread:  unread,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "render notifications page", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleNotificationsBadge(
+	renderer templates.Renderer,
+	notificationsService *notifications.Service,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		unread, err := notificationsService.CountUnread(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "count unread notifications", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := renderer.RenderNotificationsBadge(w, unread); err != nil {
+			slog.ErrorContext(r.Context(), "render notifications badge", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleMarkNotificationRead(notificationsService *notifications.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		id := parts[2]
+
+		if err := notificationsService.MarkRead(r.Context(), id); errors.Is(err, notifications.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "mark notification read", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// the card marks itself read, badges in the nav header listen to the event to refresh the count
+		w.Header().Set("HX-Trigger", "notifications-read")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleMarkAllNotificationsRead(notificationsService *notifications.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := notificationsService.MarkAllRead(r.Context()); err != nil {
+			slog.ErrorContext(r.Context(), "mark all notifications read", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/notifications/", http.StatusFound)
 	}
 }
 
@@ -308,16 +888,17 @@ func handleYearWeekStatistics(
 		prevYear, prevWeek := getPreviousISOWeek(year, week)
 
 		if err := renderer.RenderWeekStatisticsPage(w, templates.WeekStatisticsData{
-			Total:    stats.Total,
-			Year:     year,
-			Month:    int(getMonthFromISOWeek(year, week)),
-			Week:     week,
-			PrevYear: prevYear,
-			PrevWeek: prevWeek,
-			NextYear: nextYear,
-			NextWeek: nextWeek,
-			Days:     stats.Days,
-			Classes:  stats.Classes,
+			Total:      stats.Total,
+			Year:       year,
+			Month:      int(getMonthFromISOWeek(year, week)),
+			Week:       week,
+			PrevYear:   prevYear,
+			PrevWeek:   prevWeek,
+			NextYear:   nextYear,
+			NextWeek:   nextWeek,
+			Days:       stats.Days,
+			Classes:    stats.Classes,
+			Breakdowns: stats.Breakdowns,
 		}); err != nil {
 			slog.ErrorContext(r.Context(), "render month statistics page", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -361,16 +942,17 @@ func handleYearMonthStatistics(
 		nextYear, nextMonth := getNextMonth(year, month)
 		prevYear, prevMonth := getPreviousMonth(year, month)
 		if err := renderer.RenderMonthStatisticsPage(w, templates.MonthStatisticsData{
-			Total:     stats.Total,
-			Year:      year,
-			Month:     int(month),
-			Week:      firstNonEmptyWeek(stats.Weeks),
-			PrevYear:  prevYear,
-			PrevMonth: int(prevMonth),
-			NextYear:  nextYear,
-			NextMonth: int(nextMonth),
-			Weeks:     stats.Weeks,
-			Classes:   stats.Classes,
+			Total:      stats.Total,
+			Year:       year,
+			Month:      int(month),
+			Week:       firstNonEmptyWeek(stats.Weeks),
+			PrevYear:   prevYear,
+			PrevMonth:  int(prevMonth),
+			NextYear:   nextYear,
+			NextMonth:  int(nextMonth),
+			Weeks:      stats.Weeks,
+			Classes:    stats.Classes,
+			Breakdowns: stats.Breakdowns,
 		}); err != nil {
 			slog.ErrorContext(r.Context(), "render month statistics page", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -406,13 +988,22 @@ func handleYearStatistics(
 			return
 		}
 
+		metrics, err := statisticsService.CalculateMetrics(r.Context(), year)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "calculate metrics", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
 		if err := renderer.RenderYearStatisticsPage(w, templates.YearStatisticsData{
-			Total:   stats.Total,
-			Year:    year,
-			Month:   firstNonEmptyMonth(stats.Months),
-			Week:    1,
-			Months:  stats.Months,
-			Classes: stats.Classes,
+			Total:      stats.Total,
+			Year:       year,
+			Month:      firstNonEmptyMonth(stats.Months),
+			Week:       1,
+			Months:     stats.Months,
+			Classes:    stats.Classes,
+			Metrics:    metrics,
+			Breakdowns: stats.Breakdowns,
 		}); err != nil {
 			slog.ErrorContext(r.Context(), "render year statistics page", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -421,9 +1012,129 @@ func handleYearStatistics(
 	}
 }
 
+func handleYearMetricsJSON(statisticsService *statistics.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		year, err := strconv.Atoi(parts[3])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		metrics, err := statisticsService.CalculateMetrics(r.Context(), year)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "calculate metrics", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(metrics); err != nil {
+			slog.ErrorContext(r.Context(), "encode metrics", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleYearReview(
+	renderer templates.Renderer,
+	statisticsService *statistics.Service,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		year, err := strconv.Atoi(parts[3])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		review, err := statisticsService.CalculateReview(r.Context(), year)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "calculate review", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := renderer.RenderReviewPage(w, templates.ReviewData{
+			Review: review,
+		}); err != nil {
+			slog.ErrorContext(r.Context(), "render review page", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func handleYearReviewCard(
+	renderer templates.Renderer,
+	statisticsService *statistics.Service,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		year, err := strconv.Atoi(parts[3])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		review, err := statisticsService.CalculateReview(r.Context(), year)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "calculate review", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		if err := renderer.RenderReviewCard(w, review); err != nil {
+			slog.ErrorContext(r.Context(), "render review card", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// handleExportStatistics exports attended classes. The format is taken from the filename extension, the range
+// from optional from and to query parameters, both inclusive dates.
+func handleExportStatistics(statisticsService *statistics.Service) http.HandlerFunc {
+	parseDate := func(value string, fallback time.Time) (time.Time, error) {
+		if value == "" {
+			return fallback, nil
+		}
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		// classes are in Stockholm, so are the dates of the range
+		return timezone.InStockholm(date), nil
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		filename := parts[3]
+		format, err := statistics.ParseExportFormat(strings.TrimPrefix(path.Ext(filename), "."))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		from, err := parseDate(r.URL.Query().Get("from"), time.Time{})
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		to, err := parseDate(r.URL.Query().Get("to"), time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err := statisticsService.Export(r.Context(), w, format, from, to.AddDate(0, 0, 1)); err != nil {
+			// the export is streamed, the status is sent already
+			slog.ErrorContext(r.Context(), "export statistics", "error", err)
+			return
+		}
+	}
+}
+
 func handleScheduleEvents(
 	renderer templates.Renderer,
 	eventsService *events.Service,
+	timetableService *timetable.Service,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		events, err := eventsService.ListBookedEvents(r.Context())
@@ -432,6 +1143,15 @@ func handleScheduleEvents(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		if err := eventsService.SetConflicts(r.Context(), events, events); err != nil {
+			slog.ErrorContext(r.Context(), "set conflicts", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := timetableService.SetHints(r.Context(), events); err != nil {
+			// hints are optional, the page is still useful without them
+			slog.ErrorContext(r.Context(), "set timetable hints", "error", err)
+		}
 		if err := renderer.RenderSchedulePage(w, templates.EventsData{
 			Events: events,
 		}); err != nil {
@@ -445,6 +1165,7 @@ func handleScheduleEvents(
 func handleBookEvents(
 	renderer templates.Renderer,
 	eventsService *events.Service,
+	timetableService *timetable.Service,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		events, err := eventsService.ListEvents(r.Context())
@@ -453,6 +1174,11 @@ func handleBookEvents(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		eventsService.SetAlternativeTargets(events)
+		if err := timetableService.SetHints(r.Context(), events); err != nil {
+			// hints are optional, the page is still useful without them
+			slog.ErrorContext(r.Context(), "set timetable hints", "error", err)
+		}
 		if err := renderer.RenderBookPage(w, templates.EventsData{
 			Events: events,
 		}); err != nil {
@@ -463,6 +1189,23 @@ func handleBookEvents(
 	}
 }
 
+func handleSellOutsJSON(timetableService *timetable.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		analysis, err := timetableService.Analyze(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "analyze timetable", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(analysis); err != nil {
+			slog.ErrorContext(r.Context(), "encode analysis", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
 func handleLogin(
 	client *pilatescomplete.APIClient,
 	credentialsStore *credentials.Store,
@@ -490,7 +1233,7 @@ func handleLogin(
 			}
 
 			creds, err := credentialsStore.FindByLogin(r.Context(), login)
-			if errors.Is(err, badger.ErrKeyNotFound) {
+			if errors.Is(err, credentials.ErrNotFound) {
 				creds = &credentials.Credentials{
 					ID:       gonanoid.Must(),
 					Login:    login,
@@ -501,6 +1244,10 @@ func handleLogin(
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
+			} else if err != nil {
+				slog.ErrorContext(r.Context(), "find credentials", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
 			}
 
 			if err := tokensStore.Insert(r.Context(), &tokens.Token{
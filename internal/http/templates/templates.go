@@ -12,58 +12,115 @@ import (
 	"time"
 
 	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/goals"
+	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/notifications"
+	"github.com/pilatescomplete-bot/internal/pilatescomplete"
 	"github.com/pilatescomplete-bot/internal/statistics"
 )
 
 type WeekStatisticsData struct {
-	Total    int
-	Year     int
-	Month    int
-	Week     int
-	PrevYear int
-	PrevWeek int
-	NextYear int
-	NextWeek int
-	Days     []statistics.Day
-	Classes  []statistics.Class
+	Total      int
+	Year       int
+	Month      int
+	Week       int
+	PrevYear   int
+	PrevWeek   int
+	NextYear   int
+	NextWeek   int
+	Days       []statistics.Day
+	Classes    []statistics.Class
+	Breakdowns statistics.Breakdowns
 }
 
 type MonthStatisticsData struct {
-	Total     int
-	Year      int
-	Month     int
-	Week      int
-	PrevYear  int
-	PrevMonth int
-	NextYear  int
-	NextMonth int
-	Weeks     []statistics.Week
-	Classes   []statistics.Class
+	Total      int
+	Year       int
+	Month      int
+	Week       int
+	PrevYear   int
+	PrevMonth  int
+	NextYear   int
+	NextMonth  int
+	Weeks      []statistics.Week
+	Classes    []statistics.Class
+	Breakdowns statistics.Breakdowns
 }
 
 type YearStatisticsData struct {
-	Total   int
-	Year    int
-	Month   int
-	Week    int
-	Months  []statistics.Month
-	Classes []statistics.Class
+	Total      int
+	Year       int
+	Month      int
+	Week       int
+	Months     []statistics.Month
+	Classes    []statistics.Class
+	Metrics    *statistics.Metrics
+	Breakdowns statistics.Breakdowns
+}
+
+type StatisticsData struct {
+	Year  int
+	Month int
+	// WeekYear is an ISO year of the week, it differs from Year around new year
+	WeekYear int
+	Week     int
+	Goals    []*goals.Progress
+	// Telegram is true if goal nudges can be sent to telegram
+	Telegram bool
+}
+
+type ReviewData struct {
+	Review *statistics.Review
 }
 
 type LoginData struct{}
 
+type HealthData struct {
+	Skew          pilatescomplete.ClockSkew
+	SkewThreshold time.Duration
+	SkewExceeded  bool
+	LocalTime     time.Time
+	ServerTime    time.Time
+}
+
+type SettingsData struct {
+	MinLocationGapMinutes int
+	MaxBookings           int
+	MaxDailyBookings      int
+	ReserveFirstChoice    bool
+}
+
+type JobsData struct {
+	Jobs    []*jobs.Job
+	Queue   []*jobs.Job
+	History []*jobs.HistoryEntry
+}
+
 type EventsData struct {
 	Events []*events.Event
 }
 
+type NotificationsData struct {
+	Entries []*notifications.InboxEntry
+	Unread  int
+}
+
 type Renderer interface {
 	RenderBookPage(io.Writer, EventsData) error
 	RenderSchedulePage(io.Writer, EventsData) error
 	RenderEvent(io.Writer, *events.Event) error
 	RenderLoginPage(io.Writer, LoginData) error
+	RenderStatisticsPage(io.Writer, StatisticsData) error
 	RenderYearStatisticsPage(io.Writer, YearStatisticsData) error
+	RenderReviewPage(io.Writer, ReviewData) error
+	RenderReviewCard(io.Writer, *statistics.Review) error
 	RenderMonthStatisticsPage(io.Writer, MonthStatisticsData) error
 	RenderWeekStatisticsPage(io.Writer, WeekStatisticsData) error
+	RenderJobsPage(io.Writer, JobsData) error
+	RenderHealthPage(io.Writer, HealthData) error
+	RenderSettingsPage(io.Writer, SettingsData) error
+	RenderNotificationsPage(io.Writer, NotificationsData) error
+	RenderNotificationsBadge(io.Writer, int) error
 }
 
 var _ Renderer = &FilesystemTemplates{}
@@ -115,6 +172,18 @@ func (e *FilesystemTemplates) RenderMonthStatisticsPage(w io.Writer, data MonthS
 	return template.Execute(w, data)
 }
 
+func (e *FilesystemTemplates) RenderStatisticsPage(w io.Writer, data StatisticsData) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	template, err := templates.Lookup("_layout.html.template").ParseFS(e.filesystem, "statistics.html.template")
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	return template.Execute(w, data)
+}
+
 func (e *FilesystemTemplates) RenderYearStatisticsPage(w io.Writer, data YearStatisticsData) error {
 	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
 	if err != nil {
@@ -127,6 +196,26 @@ func (e *FilesystemTemplates) RenderYearStatisticsPage(w io.Writer, data YearSta
 	return template.Execute(w, data)
 }
 
+func (e *FilesystemTemplates) RenderReviewPage(w io.Writer, data ReviewData) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	template, err := templates.Lookup("_layout.html.template").ParseFS(e.filesystem, "review.html.template")
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	return template.Execute(w, data)
+}
+
+func (e *FilesystemTemplates) RenderReviewCard(w io.Writer, review *statistics.Review) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	return templates.Lookup("review-card").Execute(w, review)
+}
+
 func (e *FilesystemTemplates) RenderSchedulePage(w io.Writer, data EventsData) error {
 	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
 	if err != nil {
@@ -151,6 +240,42 @@ func (e *FilesystemTemplates) RenderBookPage(w io.Writer, data EventsData) error
 	return bookTemplate.Execute(w, data)
 }
 
+func (e *FilesystemTemplates) RenderJobsPage(w io.Writer, data JobsData) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	template, err := templates.Lookup("_layout.html.template").ParseFS(e.filesystem, "jobs.html.template")
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	return template.Execute(w, data)
+}
+
+func (e *FilesystemTemplates) RenderHealthPage(w io.Writer, data HealthData) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	template, err := templates.Lookup("_layout.html.template").ParseFS(e.filesystem, "health.html.template")
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	return template.Execute(w, data)
+}
+
+func (e *FilesystemTemplates) RenderSettingsPage(w io.Writer, data SettingsData) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	template, err := templates.Lookup("_layout.html.template").ParseFS(e.filesystem, "settings.html.template")
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	return template.Execute(w, data)
+}
+
 func (e *FilesystemTemplates) RenderEvent(w io.Writer, event *events.Event) error {
 	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
 	if err != nil {
@@ -159,6 +284,26 @@ func (e *FilesystemTemplates) RenderEvent(w io.Writer, event *events.Event) erro
 	return templates.Lookup("event").Execute(w, event)
 }
 
+func (e *FilesystemTemplates) RenderNotificationsPage(w io.Writer, data NotificationsData) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	template, err := templates.Lookup("_layout.html.template").ParseFS(e.filesystem, "notifications.html.template")
+	if err != nil {
+		return fmt.Errorf("parse template: %w", err)
+	}
+	return template.Execute(w, data)
+}
+
+func (e *FilesystemTemplates) RenderNotificationsBadge(w io.Writer, unread int) error {
+	templates, err := template.New("").Funcs(functions).ParseFS(e.filesystem, "*.template")
+	if err != nil {
+		return fmt.Errorf("parse fs: %w", err)
+	}
+	return templates.Lookup("notifications-badge").Execute(w, unread)
+}
+
 var _ Renderer = &EmbedTemplates{}
 
 type EmbedTemplates struct {
@@ -167,9 +312,17 @@ type EmbedTemplates struct {
 	scheduleTemplate        *template.Template
 	eventsTemplate          *template.Template
 	bookTemplate            *template.Template
+	statisticsTemplate      *template.Template
 	yearStatisticsTemplate  *template.Template
+	reviewTemplate          *template.Template
+	reviewCardTemplate      *template.Template
 	monthStatisticsTemplate *template.Template
 	weekStatisticsTemplate  *template.Template
+	jobsTemplate            *template.Template
+	healthTemplate          *template.Template
+	settingsTemplate        *template.Template
+	notificationsTemplate   *template.Template
+	badgeTemplate           *template.Template
 }
 
 //go:embed *.template
@@ -200,6 +353,25 @@ var functions = map[string]interface{}{
 	"shortMonthName":   func(i int) string { return time.Month(i).String()[:3] },
 	"shortWeekdayName": func(i int) string { return time.Weekday(i).String()[:3] },
 	"monthName":        func(i int) string { return time.Month(i).String() },
+	"approxDuration":   approxDuration,
+}
+
+// approxDuration formats the duration rounded up to the largest unit, e.g. "3 minutes" or "2 hours".
+func approxDuration(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d < time.Hour:
+		return plural(int(math.Ceil(d.Minutes())), "minute")
+	case d <= 24*time.Hour:
+		return plural(int(math.Ceil(d.Hours())), "hour")
+	default:
+		return plural(int(math.Ceil(d.Hours()/24)), "day")
+	}
 }
 
 func NewEmbedTemplates() *EmbedTemplates {
@@ -214,9 +386,17 @@ func NewEmbedTemplates() *EmbedTemplates {
 		bookTemplate:            template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "book.html.template")),
 		eventsTemplate:          templates.Lookup("events"),
 		eventTemplate:           templates.Lookup("event"),
+		statisticsTemplate:      template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "statistics.html.template")),
 		yearStatisticsTemplate:  template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "year_statistics.html.template")),
+		reviewTemplate:          template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "review.html.template")),
+		reviewCardTemplate:      templates.Lookup("review-card"),
 		monthStatisticsTemplate: template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "month_statistics.html.template")),
 		weekStatisticsTemplate:  template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "week_statistics.html.template")),
+		jobsTemplate:            template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "jobs.html.template")),
+		healthTemplate:          template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "health.html.template")),
+		settingsTemplate:        template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "settings.html.template")),
+		notificationsTemplate:   template.Must(template.Must(layoutTemplate.Clone()).ParseFS(embedFS, "notifications.html.template")),
+		badgeTemplate:           templates.Lookup("notifications-badge"),
 	}
 }
 
@@ -236,10 +416,22 @@ func (e *EmbedTemplates) RenderEvent(w io.Writer, event *events.Event) error {
 	return e.eventTemplate.Execute(w, event)
 }
 
+func (e *EmbedTemplates) RenderStatisticsPage(w io.Writer, data StatisticsData) error {
+	return e.statisticsTemplate.Execute(w, data)
+}
+
 func (e *EmbedTemplates) RenderYearStatisticsPage(w io.Writer, data YearStatisticsData) error {
 	return e.yearStatisticsTemplate.Execute(w, data)
 }
 
+func (e *EmbedTemplates) RenderReviewPage(w io.Writer, data ReviewData) error {
+	return e.reviewTemplate.Execute(w, data)
+}
+
+func (e *EmbedTemplates) RenderReviewCard(w io.Writer, review *statistics.Review) error {
+	return e.reviewCardTemplate.Execute(w, review)
+}
+
 func (e *EmbedTemplates) RenderMonthStatisticsPage(w io.Writer, data MonthStatisticsData) error {
 	return e.monthStatisticsTemplate.Execute(w, data)
 }
@@ -247,3 +439,23 @@ func (e *EmbedTemplates) RenderMonthStatisticsPage(w io.Writer, data MonthStatis
 func (e *EmbedTemplates) RenderWeekStatisticsPage(w io.Writer, data WeekStatisticsData) error {
 	return e.weekStatisticsTemplate.Execute(w, data)
 }
+
+func (e *EmbedTemplates) RenderJobsPage(w io.Writer, data JobsData) error {
+	return e.jobsTemplate.Execute(w, data)
+}
+
+func (e *EmbedTemplates) RenderHealthPage(w io.Writer, data HealthData) error {
+	return e.healthTemplate.Execute(w, data)
+}
+
+func (e *EmbedTemplates) RenderSettingsPage(w io.Writer, data SettingsData) error {
+	return e.settingsTemplate.Execute(w, data)
+}
+
+func (e *EmbedTemplates) RenderNotificationsPage(w io.Writer, data NotificationsData) error {
+	return e.notificationsTemplate.Execute(w, data)
+}
+
+func (e *EmbedTemplates) RenderNotificationsBadge(w io.Writer, unread int) error {
+	return e.badgeTemplate.Execute(w, unread)
+}
@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+// HistoryEntry is a compact record of a finished job, kept after the job itself is archived.
+type HistoryEntry struct {
+	ID             string    `json:"id"`
+	Time           time.Time `json:"time"`
+	Status         Status    `json:"status"`
+	EventID        string    `json:"event_id,omitempty"`
+	EventName      string    `json:"event_name,omitempty"`
+	EventStartTime time.Time `json:"event_start_time,omitempty"`
+	CredentialsID  string    `json:"credentials_id,omitempty"`
+	Attempts       int       `json:"attempts"`
+	// Error and ErrorCode are of the last attempt, if it failed.
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+func newHistoryEntry(job *Job) *HistoryEntry {
+	entry := &HistoryEntry{
+		ID:       job.ID,
+		Time:     job.Time,
+		Status:   job.Status,
+		Attempts: len(job.Attempts),
+	}
+	if attempt := job.LastAttempt(); attempt != nil {
+		entry.Error = attempt.Error
+		entry.ErrorCode = attempt.ErrorCode
+	}
+	if job.BookEvent != nil {
+		entry.EventID = job.BookEvent.EventID
+		entry.EventName = job.BookEvent.EventName
+		entry.EventStartTime = job.BookEvent.EventStartTime
+		entry.CredentialsID = job.BookEvent.CredentialsID
+	}
+	return entry
+}
+
+// isFinished returns true if job will not be run again.
+func isFinished(job *Job) bool {
+	switch job.Status {
+	case StatusSucceded, StatusMissed:
+		return true
+	case StatusFailing:
+		return nextRetry(job) == nil
+	default:
+		return false
+	}
+}
+
+// archiveBatchSize is a number of jobs archived in one transaction, so that transactions stay small enough on large
+// stores.
+const archiveBatchSize = 500
+
+// errBatchFull stops iteration once a batch of jobs to archive is collected.
+var errBatchFull = errors.New("batch full")
+
+// Archive replaces finished jobs scheduled before the given time with history entries, in batches.
+// Returns number of archived jobs.
+func (s *Store) Archive(ctx context.Context, before time.Time) (int, error) {
+	count := 0
+	for {
+		archived, err := s.archiveBatch(ctx, before)
+		count += archived
+		if err != nil {
+			return count, err
+		}
+		if archived < archiveBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// archiveBatch archives up to archiveBatchSize jobs in one transaction. Returns number of archived jobs.
+func (s *Store) archiveBatch(_ context.Context, before time.Time) (int, error) {
+	var batch []*Job
+	if err := s.db.Update(func(txn kv.Txn) error {
+		for _, status := range []Status{StatusSucceded, StatusFailing, StatusMissed} {
+			if err := txn.Iterate(statusIndexPrefix(status), func(_ []byte, jobKey []byte) error {
+				value, err := txn.Get(jobKey)
+				if err != nil {
+					return fmt.Errorf("%q: %w", jobKey, err)
+				}
+				job := &Job{}
+				if err := json.Unmarshal(value, &job); err != nil {
+					return err
+				}
+				if !isFinished(job) || !job.Time.Before(before) {
+					return nil
+				}
+				batch = append(batch, job)
+				if len(batch) == archiveBatchSize {
+					return errBatchFull
+				}
+				return nil
+			}); errors.Is(err, errBatchFull) {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		for _, job := range batch {
+			data, err := json.Marshal(newHistoryEntry(job))
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(historyKey(job), data); err != nil {
+				return err
+			}
+			if err := deleteIndexKeys(txn, job.ID); err != nil {
+				return err
+			}
+			if err := txn.Delete(idKey(job.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// ListHistory returns history entries of archived jobs for the credentials id.
+func (s *Store) ListHistory(_ context.Context, credentialsID string) ([]*HistoryEntry, error) {
+	var entries []*HistoryEntry
+	if err := s.db.View(func(txn kv.Txn) error {
+		return txn.Iterate(historyPrefix(credentialsID), func(_ []byte, value []byte) error {
+			entry := &HistoryEntry{}
+			if err := json.Unmarshal(value, entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func historyPrefix(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("jobs_history/%s/", credentialsID))
+}
+
+func historyKey(job *Job) []byte {
+	credentialsID := ""
+	if job.BookEvent != nil {
+		credentialsID = job.BookEvent.CredentialsID
+	}
+	return append(historyPrefix(credentialsID), job.ID...)
+}
@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	for _, job := range []*Job{
+		{ID: "succeeded", Time: old, Status: StatusSucceded, Attempts: []*Attempt{{StartedAt: old}}, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}},
+		{ID: "failed", Time: old, Status: StatusFailing, Attempts: []*Attempt{{StartedAt: old, Error: "full"}}, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "y"}},
+		{ID: "recent", Time: now, Status: StatusSucceded, Attempts: []*Attempt{{StartedAt: now}}, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "z"}},
+		{ID: "pending", Time: old, Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "w"}},
+	} {
+		if err := store.InsertJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archived, err := store.Archive(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived != 2 {
+		t.Fatalf("expected 2 archived jobs, got %d", archived)
+	}
+
+	jobs, err := store.ListJobs(ctx, ByCredentialsID("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(jobs); !slices.Equal(got, []string{"pending", "recent"}) {
+		t.Fatalf("expected [pending recent], got %v", got)
+	}
+
+	history, err := store.ListHistory(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", len(history))
+	}
+	for _, entry := range history {
+		if entry.ID == "failed" && entry.Error != "full" {
+			t.Errorf("expected error %q, got %q", "full", entry.Error)
+		}
+	}
+}
+
+func TestArchiveBatches(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	old := time.Now().Add(-48 * time.Hour)
+	jobs := make([]*Job, 0, 2*archiveBatchSize+1)
+	for i := range 2*archiveBatchSize + 1 {
+		jobs = append(jobs, &Job{ID: fmt.Sprint(i), Time: old, Status: StatusSucceded, BookEvent: &BookEventJob{CredentialsID: "a", EventID: fmt.Sprint(i)}})
+	}
+	if err := store.InsertJobs(ctx, jobs...); err != nil {
+		t.Fatal(err)
+	}
+
+	archived, err := store.Archive(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived != len(jobs) {
+		t.Fatalf("expected %d archived jobs, got %d", len(jobs), archived)
+	}
+	if left, err := store.ListJobs(ctx); err != nil || len(left) != 0 {
+		t.Fatalf("expected no jobs left, got %d %v", len(left), err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	gonanoid "github.com/matoous/go-nanoid/v2"
@@ -19,44 +20,183 @@ const (
 	StatusRunning
 	StatusSucceded
 	StatusFailing
+	// StatusMissed is a job that was not run because it became due while the scheduler was not running.
+	StatusMissed
+	// StatusQueued is a job held back because user is at their bookings limit, until the planner releases it.
+	StatusQueued
 )
 
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusRunning:
+		return "running"
+	case StatusSucceded:
+		return "succeeded"
+	case StatusFailing:
+		return "failed"
+	case StatusMissed:
+		return "missed"
+	case StatusQueued:
+		return "queued"
+	default:
+		return "undefined"
+	}
+}
+
+type Type string
+
+const (
+	TypeUndefined Type = ""
+	TypeBookEvent Type = "book_event"
+)
+
+// MisfirePolicy decides what happens to a job that is due, but late, for example because the server was down.
+type MisfirePolicy struct {
+	// MaxDelay is how late a job is still run. Later jobs are marked as missed. Zero means no limit.
+	MaxDelay time.Duration
+}
+
+// Misfired returns true if a job due at ts should not run at now.
+func (p MisfirePolicy) Misfired(ts time.Time, now time.Time) bool {
+	return p.MaxDelay > 0 && now.Sub(ts) > p.MaxDelay
+}
+
 type Job struct {
-	ID       string      `json:"id"`
-	Time     time.Time   `json:"time"`
-	Status   Status      `json:"status"`
-	Attempts []time.Time `json:"attempts"`
-	Errors   []string    `json:"errors"`
+	ID       string     `json:"id"`
+	Time     time.Time  `json:"time"`
+	Status   Status     `json:"status"`
+	Attempts []*Attempt `json:"attempts"`
+	// Priority orders queued jobs of a user, lower is released first.
+	Priority int `json:"priority,omitempty"`
 
 	BookEvent *BookEventJob `json:"book_event,omitempty"`
 }
 
+func (j Job) Type() Type {
+	if j.BookEvent != nil {
+		return TypeBookEvent
+	}
+	return TypeUndefined
+}
+
+// LastAttempt returns the latest attempt to run the job, or nil if the job was never run.
+func (j Job) LastAttempt() *Attempt {
+	if len(j.Attempts) == 0 {
+		return nil
+	}
+	return j.Attempts[len(j.Attempts)-1]
+}
+
+// Attempt is a record of a single run of a job.
+type Attempt struct {
+	StartedAt time.Time     `json:"started_at"`
+	Latency   time.Duration `json:"latency"`
+	// Error is empty if the attempt succeeded.
+	Error string `json:"error,omitempty"`
+	// ErrorCode is an error code returned by the API, if any.
+	ErrorCode string `json:"error_code,omitempty"`
+	// BookingStatus and Position describe the booking made by the attempt.
+	BookingStatus pilatescomplete.ActivityBookingStatus `json:"booking_status,omitempty"`
+	Position      int64                                 `json:"position,omitempty"`
+	// BookedEventID is an id of the event that was booked, either the first choice or one of the alternatives.
+	BookedEventID string `json:"booked_event_id,omitempty"`
+	// TokenRefreshed is true if the attempt had to log in to get a new token.
+	TokenRefreshed bool `json:"token_refreshed"`
+}
+
+// BookedEventID returns id of the event booked by the last attempt, falling back to the first choice.
+func (j Job) BookedEventID() string {
+	if attempt := j.LastAttempt(); attempt != nil && attempt.BookedEventID != "" {
+		return attempt.BookedEventID
+	}
+	if j.BookEvent != nil {
+		return j.BookEvent.EventID
+	}
+	return ""
+}
+
 type BookEventJob struct {
 	EventID       string `json:"events_id"`
 	CredentialsID string `json:"credentials_id"`
+	// EventName and EventStartTime are kept to show the job without fetching the event.
+	EventName      string    `json:"event_name,omitempty"`
+	EventStartTime time.Time `json:"event_start_time,omitempty"`
+	// EventEndTime and EventLocation are kept to find conflicts without fetching the event, they are empty for jobs
+	// scheduled before they were added.
+	EventEndTime  time.Time `json:"event_end_time,omitempty"`
+	EventLocation string    `json:"event_location,omitempty"`
+	// Alternatives are ids of events to book, in order, if the event can not be booked.
+	Alternatives []string `json:"alternatives,omitempty"`
+	// ReserveFirstChoice keeps a reservation of the event when it's full, instead of trying the alternatives.
+	ReserveFirstChoice bool `json:"reserve_first_choice,omitempty"`
+}
+
+// EventIDs returns ids of the event and its alternatives, in order of preference.
+func (j BookEventJob) EventIDs() []string {
+	return append([]string{j.EventID}, j.Alternatives...)
 }
 
-func (j Job) Do(ctx context.Context, s *Scheduler) error {
+// Do runs the job, recording the outcome into attempt.
+func (j Job) Do(ctx context.Context, s *Scheduler, attempt *Attempt) error {
 	if j.BookEvent != nil {
-		ctx, err := s.authenticationService.AuthenticateContext(ctx, j.BookEvent.CredentialsID)
+		ctx, refreshed, err := s.authenticationService.Authenticate(ctx, j.BookEvent.CredentialsID)
+		attempt.TokenRefreshed = refreshed
 		if err != nil {
 			return fmt.Errorf("authenticate context: %w", err)
 		}
 
-		if _, err := s.apiClient.BookActivity(ctx, string(j.BookEvent.EventID)); errors.Is(err, pilatescomplete.ErrActivityAlreadyBooked) {
+		return j.BookEvent.book(ctx, s.apiClient, attempt)
+	}
+	return fmt.Errorf("unsupported job type")
+}
+
+// book books the first of the event and its alternatives that has places left. A reservation is kept
+// only on the first choice if ReserveFirstChoice is set, or on the last alternative.
+func (j BookEventJob) book(ctx context.Context, apiClient *pilatescomplete.APIClient, attempt *Attempt) error {
+	var lastErr error
+	eventIDs := j.EventIDs()
+	for i, eventID := range eventIDs {
+		last := i == len(eventIDs)-1
+		booking, err := apiClient.BookActivity(ctx, eventID)
+		if errors.Is(err, pilatescomplete.ErrActivityAlreadyBooked) {
+			attempt.BookedEventID = eventID
 			return nil
-		} else if err != nil {
+		} else if errors.Is(err, pilatescomplete.ErrOverbooked) || errors.Is(err, pilatescomplete.ErrAccessNotAllowed) {
+			// limits apply to alternatives as well
 			return err
+		} else if err != nil {
+			slog.InfoContext(ctx, "book alternative", "event_id", eventID, "error", err)
+			lastErr = err
+			continue
 		}
 
-		return nil
+		keep := booking.Status != pilatescomplete.ActivityBookingStatusReserved || last || (i == 0 && j.ReserveFirstChoice)
+		if !keep {
+			if err := apiClient.CancelBooking(ctx, booking.BookingID); err != nil {
+				// can't try alternatives without risking two bookings
+				slog.ErrorContext(ctx, "cancel reservation", "event_id", eventID, "error", err)
+				keep = true
+			}
+		}
+		if keep {
+			attempt.BookingStatus = booking.Status
+			attempt.Position = booking.Position.Int64()
+			attempt.BookedEventID = eventID
+			return nil
+		}
 	}
-	return fmt.Errorf("unsupported job type")
+	return lastErr
 }
 
 func NewBookEventJob(
 	ctx context.Context,
 	eventID string,
+	eventName string,
+	eventStartTime time.Time,
+	eventEndTime time.Time,
+	eventLocation string,
 	ts time.Time,
 ) (*Job, error) {
 	token, ok := tokens.FromContext(ctx)
@@ -68,8 +208,12 @@ func NewBookEventJob(
 		Status: StatusPending,
 		Time:   ts,
 		BookEvent: &BookEventJob{
-			EventID:       eventID,
-			CredentialsID: token.CredentialsID,
+			EventID:        eventID,
+			CredentialsID:  token.CredentialsID,
+			EventName:      eventName,
+			EventStartTime: eventStartTime,
+			EventEndTime:   eventEndTime,
+			EventLocation:  eventLocation,
 		},
 	}, nil
 }
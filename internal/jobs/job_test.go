@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMisfirePolicy(t *testing.T) {
+	due := time.Date(2024, time.September, 1, 7, 0, 1, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		policy   MisfirePolicy
+		now      time.Time
+		expected bool
+	}{
+		"no limit":     {MisfirePolicy{}, due.Add(24 * time.Hour), false},
+		"on time":      {MisfirePolicy{MaxDelay: time.Minute}, due, false},
+		"within limit": {MisfirePolicy{MaxDelay: time.Minute}, due.Add(30 * time.Second), false},
+		"after limit":  {MisfirePolicy{MaxDelay: time.Minute}, due.Add(2 * time.Minute), true},
+		"before due":   {MisfirePolicy{MaxDelay: time.Minute}, due.Add(-time.Hour), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.policy.Misfired(due, tc.now); got != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBookedEventID(t *testing.T) {
+	job := &Job{BookEvent: &BookEventJob{EventID: "first", Alternatives: []string{"second", "third"}}}
+	if got := job.BookEvent.EventIDs(); !slices.Equal(got, []string{"first", "second", "third"}) {
+		t.Fatalf("expected [first second third], got %v", got)
+	}
+	if got := job.BookedEventID(); got != "first" {
+		t.Fatalf("expected first, got %s", got)
+	}
+	job.Attempts = append(job.Attempts, &Attempt{BookedEventID: "third"})
+	if got := job.BookedEventID(); got != "third" {
+		t.Fatalf("expected third, got %s", got)
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/pilatescomplete-bot/internal/authentication"
 	"github.com/pilatescomplete-bot/internal/pilatescomplete"
 	"github.com/pilatescomplete-bot/internal/tokens"
@@ -16,6 +16,9 @@ import (
 
 const MAX_ATTEMPTS = 1
 
+// ErrJobRunning is returned when a job is changed while it runs.
+var ErrJobRunning = errors.New("job is running")
+
 type Scheduler struct {
 	store                 *Store
 	apiClient             *pilatescomplete.APIClient
@@ -23,9 +26,15 @@ type Scheduler struct {
 
 	jobsGuard sync.RWMutex
 	jobs      map[string]*Job
+	// running are ids of jobs picked up by the tick loop, they can not be updated until the run is over.
+	running map[string]bool
+
+	misfirePolicies map[Type]MisfirePolicy
 
 	jobFailedCallbacks    []func(context.Context, *Job)
 	jobSucceededCallbacks []func(context.Context, *Job)
+	jobMissedCallbacks    []func(context.Context, *Job)
+	jobQueuedCallbacks    []func(context.Context, *Job)
 }
 
 func NewScheduler(
@@ -38,9 +47,16 @@ func NewScheduler(
 		apiClient:             apiClient,
 		authenticationService: authenticationService,
 		jobs:                  make(map[string]*Job),
+		running:               make(map[string]bool),
+		misfirePolicies:       make(map[Type]MisfirePolicy),
 	}
 }
 
+// SetMisfirePolicy sets misfire policy for jobs of the type. Jobs without a policy run however late they are.
+func (s *Scheduler) SetMisfirePolicy(jobType Type, policy MisfirePolicy) {
+	s.misfirePolicies[jobType] = policy
+}
+
 func (s *Scheduler) OnJobFailed(cb func(context.Context, *Job)) {
 	s.jobFailedCallbacks = append(s.jobFailedCallbacks, cb)
 }
@@ -49,9 +65,26 @@ func (s *Scheduler) OnJobSucceeded(cb func(context.Context, *Job)) {
 	s.jobSucceededCallbacks = append(s.jobSucceededCallbacks, cb)
 }
 
+func (s *Scheduler) OnJobMissed(cb func(context.Context, *Job)) {
+	s.jobMissedCallbacks = append(s.jobMissedCallbacks, cb)
+}
+
+func (s *Scheduler) OnJobQueued(cb func(context.Context, *Job)) {
+	s.jobQueuedCallbacks = append(s.jobQueuedCallbacks, cb)
+}
+
 // Init will load all pending jobs from database into memeory, and start watching them.
+// Jobs that were running when the scheduler stopped are recovered first.
 func (s *Scheduler) Init(ctx context.Context) error {
-	jobs, err := s.store.ListJobs(ctx, ByStatus(StatusPending, StatusFailing, StatusRunning), ExcludeFailed())
+	running, err := s.store.ListJobs(ctx, ByStatus(StatusRunning))
+	if err != nil {
+		return err
+	}
+	for _, job := range running {
+		s.recoverJob(ctx, job)
+	}
+
+	jobs, err := s.store.ListJobs(ctx, ByStatus(StatusPending, StatusFailing), ExcludeFailed())
 	if err != nil {
 		return err
 	}
@@ -66,26 +99,21 @@ func (s *Scheduler) Init(ctx context.Context) error {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
+				// jobs are due by the server clock, booking opens at the server time. the clock never runs ahead of the server,
+				// so jobs never fire too early
+				now := s.apiClient.Clock().Now()
 				jobsToRun := []*Job{}
-				s.jobsGuard.RLock()
+				s.jobsGuard.Lock()
 				for _, job := range s.jobs {
-					if time.Now().After(job.Time) {
+					if now.After(job.Time) {
 						jobsToRun = append(jobsToRun, job)
+						s.running[job.ID] = true
 					}
 				}
-				s.jobsGuard.RUnlock()
+				s.jobsGuard.Unlock()
 
 				for _, job := range jobsToRun {
-					slog.InfoContext(ctx, "starting job", "job_id", job.ID, "attempt", len(job.Attempts))
-					if err := s.runJob(ctx, job); err != nil {
-						for _, cb := range s.jobFailedCallbacks {
-							cb(ctx, job)
-						}
-					} else {
-						for _, cb := range s.jobSucceededCallbacks {
-							cb(ctx, job)
-						}
-					}
+					s.runDueJob(ctx, job, now)
 				}
 			}
 		}
@@ -94,15 +122,43 @@ func (s *Scheduler) Init(ctx context.Context) error {
 	return nil
 }
 
+// runDueJob runs the job, or marks it missed if it's too late to run.
+func (s *Scheduler) runDueJob(ctx context.Context, job *Job, now time.Time) {
+	defer func() {
+		s.jobsGuard.Lock()
+		delete(s.running, job.ID)
+		s.jobsGuard.Unlock()
+	}()
+
+	if s.misfirePolicies[job.Type()].Misfired(job.Time, now) {
+		if err := s.MarkMissed(ctx, job); err != nil {
+			slog.ErrorContext(ctx, "mark job missed", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+	slog.InfoContext(ctx, "starting job", "job_id", job.ID, "attempt", len(job.Attempts))
+	if err := s.runJob(ctx, job); job.Status == StatusQueued {
+		for _, cb := range s.jobQueuedCallbacks {
+			cb(ctx, job)
+		}
+	} else if err != nil {
+		for _, cb := range s.jobFailedCallbacks {
+			cb(ctx, job)
+		}
+	} else {
+		for _, cb := range s.jobSucceededCallbacks {
+			cb(ctx, job)
+		}
+	}
+}
+
 func (s *Scheduler) FindByID(ctx context.Context, id string) (*Job, error) {
 	token, ok := tokens.FromContext(ctx)
 	if !ok {
 		return nil, fmt.Errorf("token missing from context")
 	}
 	job, err := s.store.FindByID(ctx, id)
-	if errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, ErrNotFound
-	} else if err != nil {
+	if err != nil {
 		return nil, err
 	}
 	if job.BookEvent.CredentialsID != token.CredentialsID {
@@ -111,6 +167,38 @@ func (s *Scheduler) FindByID(ctx context.Context, id string) (*Job, error) {
 	return job, nil
 }
 
+// ListJobs returns jobs of the authenticated user, latest first.
+func (s *Scheduler) ListJobs(ctx context.Context) ([]*Job, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("token missing from context")
+	}
+	jobs, err := s.store.ListJobs(ctx, ByCredentialsID(token.CredentialsID))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return b.Time.Compare(a.Time)
+	})
+	return jobs, nil
+}
+
+// ListHistory returns archived jobs of the authenticated user, latest first.
+func (s *Scheduler) ListHistory(ctx context.Context) ([]*HistoryEntry, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("token missing from context")
+	}
+	entries, err := s.store.ListHistory(ctx, token.CredentialsID)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b *HistoryEntry) int {
+		return b.Time.Compare(a.Time)
+	})
+	return entries, nil
+}
+
 func (s *Scheduler) DeleteByID(ctx context.Context, id string) error {
 	token, ok := tokens.FromContext(ctx)
 	if !ok {
@@ -131,14 +219,164 @@ func (s *Scheduler) DeleteByID(ctx context.Context, id string) error {
 	return nil
 }
 
-func (s *Scheduler) Schedule(ctx context.Context, job *Job) error {
+// Schedule schedules the job, unless an equivalent job is scheduled already. Returns the scheduled job,
+// which is the existing one in that case.
+func (s *Scheduler) Schedule(ctx context.Context, job *Job) (*Job, error) {
+	scheduled, err := s.store.InsertUniqueJob(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert job: %w", err)
+	}
+	if scheduled.ID != job.ID {
+		slog.InfoContext(ctx, "job already scheduled", "job_id", scheduled.ID)
+		return scheduled, nil
+	}
+	s.setupTimerForJob(ctx, job)
+	return job, nil
+}
+
+// AddAlternative adds the event as the last alternative of the authenticated user's booking job.
+func (s *Scheduler) AddAlternative(ctx context.Context, id string, eventID string, reserveFirstChoice bool) (*Job, error) {
+	job, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.BookEvent == nil || (job.Status != StatusPending && job.Status != StatusQueued) {
+		return nil, fmt.Errorf("job %q can't have alternatives", id)
+	}
+	if slices.Contains(job.BookEvent.EventIDs(), eventID) {
+		return job, nil
+	}
+	job.BookEvent.Alternatives = append(job.BookEvent.Alternatives, eventID)
+	job.BookEvent.ReserveFirstChoice = reserveFirstChoice
+	if err := s.updateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RemoveAlternative removes the event from alternatives of the authenticated user's booking job.
+func (s *Scheduler) RemoveAlternative(ctx context.Context, id string, eventID string) (*Job, error) {
+	job, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.BookEvent == nil {
+		return nil, fmt.Errorf("job %q can't have alternatives", id)
+	}
+	job.BookEvent.Alternatives = slices.DeleteFunc(job.BookEvent.Alternatives, func(alternative string) bool {
+		return alternative == eventID
+	})
+	if err := s.updateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// updateJob stores the job, and replaces the pending copy, so that the change is picked up when it runs.
+// Returns ErrJobRunning if the job is running, as the run would overwrite the change.
+func (s *Scheduler) updateJob(ctx context.Context, job *Job) error {
+	s.jobsGuard.Lock()
+	defer s.jobsGuard.Unlock()
+	if s.running[job.ID] {
+		return ErrJobRunning
+	}
 	if err := s.store.InsertJob(ctx, job); err != nil {
-		return fmt.Errorf("failed to insert job: %w", err)
+		return fmt.Errorf("insert job: %w", err)
+	}
+	if _, ok := s.jobs[job.ID]; ok {
+		s.jobs[job.ID] = job
+	}
+	return nil
+}
+
+// Queue queues the job until the planner releases it, unless an equivalent job is scheduled already.
+// Returns the queued job, which is the existing one in that case.
+func (s *Scheduler) Queue(ctx context.Context, job *Job) (*Job, error) {
+	job.Status = StatusQueued
+	priority, err := s.nextPriority(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("next priority: %w", err)
+	}
+	job.Priority = priority
+	queued, err := s.store.InsertUniqueJob(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert job: %w", err)
+	}
+	if queued.ID != job.ID {
+		slog.InfoContext(ctx, "job already scheduled", "job_id", queued.ID)
+		return queued, nil
+	}
+	slog.InfoContext(ctx, "queued job", "job_id", job.ID, "priority", job.Priority)
+	return job, nil
+}
+
+// Release schedules a queued job to run right away.
+func (s *Scheduler) Release(ctx context.Context, job *Job) error {
+	job.Status = StatusPending
+	job.Time = s.apiClient.Clock().Now()
+	if err := s.store.InsertJob(ctx, job); err != nil {
+		return fmt.Errorf("insert job: %w", err)
 	}
 	s.setupTimerForJob(ctx, job)
 	return nil
 }
 
+// ListQueuedJobs returns queued jobs of the credentials id, in priority order.
+func (s *Scheduler) ListQueuedJobs(ctx context.Context, credentialsID string) ([]*Job, error) {
+	jobs, err := s.store.ListJobs(ctx, ByCredentialsID(credentialsID), ByStatus(StatusQueued))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return a.Priority - b.Priority
+	})
+	return jobs, nil
+}
+
+// MoveQueuedJob swaps priority of the authenticated user's queued job with the previous one, or with the next one
+// if up is false.
+func (s *Scheduler) MoveQueuedJob(ctx context.Context, id string, up bool) error {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return fmt.Errorf("token missing from context")
+	}
+	queue, err := s.ListQueuedJobs(ctx, token.CredentialsID)
+	if err != nil {
+		return fmt.Errorf("list queued jobs: %w", err)
+	}
+	i := slices.IndexFunc(queue, func(job *Job) bool { return job.ID == id })
+	if i == -1 {
+		return ErrNotFound
+	}
+	j := i + 1
+	if up {
+		j = i - 1
+	}
+	if j < 0 || j >= len(queue) {
+		return nil
+	}
+	queue[i].Priority, queue[j].Priority = queue[j].Priority, queue[i].Priority
+	if err := s.store.InsertJobs(ctx, queue[i], queue[j]); err != nil {
+		return fmt.Errorf("insert jobs: %w", err)
+	}
+	return nil
+}
+
+// nextPriority returns priority that puts the job at the end of its user's queue.
+func (s *Scheduler) nextPriority(ctx context.Context, job *Job) (int, error) {
+	if job.BookEvent == nil {
+		return 0, nil
+	}
+	queue, err := s.ListQueuedJobs(ctx, job.BookEvent.CredentialsID)
+	if err != nil {
+		return 0, err
+	}
+	if len(queue) == 0 {
+		return 1, nil
+	}
+	return queue[len(queue)-1].Priority + 1, nil
+}
+
 func (s *Scheduler) deleteTimer(ctx context.Context, job *Job) {
 	s.jobsGuard.Lock()
 	delete(s.jobs, job.ID)
@@ -157,25 +395,40 @@ func (s *Scheduler) runJob(ctx context.Context, job *Job) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	attempt := &Attempt{
+		StartedAt: time.Now(),
+	}
 	job.Status = StatusRunning
-	job.Attempts = append(job.Attempts, time.Now())
+	job.Attempts = append(job.Attempts, attempt)
 
 	if err := s.store.InsertJob(ctx, job); err != nil {
 		return fmt.Errorf("insert job: %w", err)
 	}
 
-	jobError := job.Do(ctx, s)
+	jobError := job.Do(ctx, s, attempt)
+	attempt.Latency = time.Since(attempt.StartedAt)
 	if jobError != nil {
-		job.Errors = append(job.Errors, jobError.Error())
+		attempt.Error = jobError.Error()
+		attempt.ErrorCode = pilatescomplete.ErrorCode(jobError)
 		job.Status = StatusFailing
-		if next := nextRetry(job); next != nil {
+		if errors.Is(jobError, pilatescomplete.ErrOverbooked) || errors.Is(jobError, pilatescomplete.ErrAccessNotAllowed) {
+			// user is at their limit, wait for the planner instead of failing
+			job.Status = StatusQueued
+			if job.Priority == 0 {
+				priority, err := s.nextPriority(ctx, job)
+				if err != nil {
+					return fmt.Errorf("next priority: %w", err)
+				}
+				job.Priority = priority
+			}
+			s.deleteTimer(ctx, job)
+		} else if next := nextRetry(job); next != nil {
 			job.Time = *next
 		} else {
 			s.deleteTimer(ctx, job)
 		}
 	} else {
 		job.Status = StatusSucceded
-		job.Errors = append(job.Errors, "")
 		s.deleteTimer(ctx, job)
 	}
 
@@ -186,6 +439,86 @@ func (s *Scheduler) runJob(ctx context.Context, job *Job) error {
 	return jobError
 }
 
+// MarkMissed marks the job as missed, it will not be run.
+func (s *Scheduler) MarkMissed(ctx context.Context, job *Job) error {
+	slog.WarnContext(ctx, "job missed", "job_id", job.ID, "due", job.Time)
+	job.Status = StatusMissed
+	s.deleteTimer(ctx, job)
+	if err := s.store.InsertJob(ctx, job); err != nil {
+		return fmt.Errorf("insert job: %w", err)
+	}
+	for _, cb := range s.jobMissedCallbacks {
+		cb(ctx, job)
+	}
+	return nil
+}
+
+// recoverJob resolves a job that was left running, because the scheduler stopped in the middle of it.
+// If the job turns out to be done, it's marked as succeeded, otherwise it's scheduled to run again.
+func (s *Scheduler) recoverJob(ctx context.Context, job *Job) {
+	attempt := job.LastAttempt()
+	if attempt == nil {
+		attempt = &Attempt{StartedAt: job.Time}
+		job.Attempts = append(job.Attempts, attempt)
+	}
+
+	done, err := s.checkDone(ctx, job, attempt)
+	if err != nil {
+		// running the job again is safe, booking an already booked event succeeds
+		slog.ErrorContext(ctx, "check job state", "job_id", job.ID, "error", err)
+	}
+
+	if done {
+		slog.InfoContext(ctx, "recovered job", "job_id", job.ID, "status", "succeeded")
+		job.Status = StatusSucceded
+	} else {
+		slog.InfoContext(ctx, "recovered job", "job_id", job.ID, "status", "pending")
+		attempt.Error = "interrupted"
+		job.Status = StatusPending
+	}
+	if err := s.store.InsertJob(ctx, job); err != nil {
+		slog.ErrorContext(ctx, "insert job", "job_id", job.ID, "error", err)
+		return
+	}
+
+	if done {
+		for _, cb := range s.jobSucceededCallbacks {
+			cb(ctx, job)
+		}
+	} else {
+		s.setupTimerForJob(ctx, job)
+	}
+}
+
+// checkDone checks through the API if the job is done, recording the outcome into attempt.
+func (s *Scheduler) checkDone(ctx context.Context, job *Job, attempt *Attempt) (bool, error) {
+	if job.BookEvent == nil {
+		return false, fmt.Errorf("unsupported job type")
+	}
+	ctx, err := s.authenticationService.AuthenticateContext(ctx, job.BookEvent.CredentialsID)
+	if err != nil {
+		return false, fmt.Errorf("authenticate context: %w", err)
+	}
+	for _, eventID := range job.BookEvent.EventIDs() {
+		response, err := s.apiClient.ListEvents(ctx, pilatescomplete.ListEventsInput{
+			ActivityID: eventID,
+		})
+		if err != nil {
+			return false, fmt.Errorf("list events: %w", err)
+		}
+		for _, event := range response.Events {
+			if event.Activity.ID != eventID || event.ActivityBooking == nil {
+				continue
+			}
+			attempt.BookingStatus = event.ActivityBooking.Status
+			attempt.Position = event.ActivityBooking.Position.Int64()
+			attempt.BookedEventID = eventID
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func nextRetry(job *Job) *time.Time {
 	if len(job.Attempts) >= MAX_ATTEMPTS {
 		return nil
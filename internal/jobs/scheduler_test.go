@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := tokens.NewContext(context.Background(), &tokens.Token{CredentialsID: "a"})
+	scheduler := NewScheduler(NewStore(kv.NewMemory()), nil, nil)
+
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := scheduler.Queue(ctx, &Job{ID: id, BookEvent: &BookEventJob{CredentialsID: "a", EventID: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// queueing the same event again keeps the existing job
+	queued, err := scheduler.Queue(ctx, &Job{ID: "4", BookEvent: &BookEventJob{CredentialsID: "a", EventID: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued.ID != "1" {
+		t.Fatalf("expected existing job 1, got %s", queued.ID)
+	}
+
+	queueIDs := func() []string {
+		queue, err := scheduler.ListQueuedJobs(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, job := range queue {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	if got := queueIDs(); !slices.Equal(got, []string{"1", "2", "3"}) {
+		t.Fatalf("expected [1 2 3], got %v", got)
+	}
+
+	if err := scheduler.MoveQueuedJob(ctx, "3", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueIDs(); !slices.Equal(got, []string{"1", "3", "2"}) {
+		t.Fatalf("expected [1 3 2], got %v", got)
+	}
+
+	// moving the first job up is a no-op
+	if err := scheduler.MoveQueuedJob(ctx, "1", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueIDs(); !slices.Equal(got, []string{"1", "3", "2"}) {
+		t.Fatalf("expected [1 3 2], got %v", got)
+	}
+
+	other := tokens.NewContext(context.Background(), &tokens.Token{CredentialsID: "b"})
+	if err := scheduler.MoveQueuedJob(other, "1", false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	ctx := tokens.NewContext(context.Background(), &tokens.Token{CredentialsID: "a"})
+	store := NewStore(kv.NewMemory())
+	scheduler := NewScheduler(store, nil, nil)
+
+	if err := store.InsertJob(ctx, &Job{ID: "1", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, eventID := range []string{"y", "z", "y", "x"} {
+		if _, err := scheduler.AddAlternative(ctx, "1", eventID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	job, err := scheduler.RemoveAlternative(ctx, "1", "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(job.BookEvent.Alternatives, []string{"z"}) {
+		t.Fatalf("expected [z], got %v", job.BookEvent.Alternatives)
+	}
+
+	stored, err := store.FindByID(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(stored.BookEvent.Alternatives, []string{"z"}) || !stored.BookEvent.ReserveFirstChoice {
+		t.Fatalf("unexpected stored job: %+v", stored.BookEvent)
+	}
+}
+
+func TestAlternativesOfRunningJob(t *testing.T) {
+	ctx := tokens.NewContext(context.Background(), &tokens.Token{CredentialsID: "a"})
+	store := NewStore(kv.NewMemory())
+	scheduler := NewScheduler(store, nil, nil)
+
+	if err := store.InsertJob(ctx, &Job{ID: "1", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	// picked up by the tick loop
+	scheduler.running["1"] = true
+
+	if _, err := scheduler.AddAlternative(ctx, "1", "y", false); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("expected ErrJobRunning, got %v", err)
+	}
+
+	stored, err := store.FindByID(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.BookEvent.Alternatives) != 0 {
+		t.Fatalf("expected no alternatives, got %v", stored.BookEvent.Alternatives)
+	}
+}
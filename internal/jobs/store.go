@@ -6,85 +6,166 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 
-	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 type Store struct {
-	db *badger.DB
+	db kv.Store
 }
 
-func NewStore(db *badger.DB) *Store {
+func NewStore(db kv.Store) *Store {
 	return &Store{
 		db: db,
 	}
 }
 
 func (s *Store) InsertJob(_ context.Context, job *Job) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		data, err := json.Marshal(job)
-		if err != nil {
-			return err
-		}
-		if err := txn.Set(idKey(job.ID), data); err != nil {
-			return err
+	return s.db.Update(func(txn kv.Txn) error {
+		return insertJob(txn, job)
+	})
+}
+
+// InsertJobs inserts all of the jobs in a single transaction.
+func (s *Store) InsertJobs(_ context.Context, jobs ...*Job) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		for _, job := range jobs {
+			if err := insertJob(txn, job); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
 }
 
+// InsertUniqueJob inserts the job, unless there already is an active job booking the same event for the same
+// credentials. Returns the active job, which is either the inserted or the existing one.
+func (s *Store) InsertUniqueJob(_ context.Context, job *Job) (*Job, error) {
+	active := job
+	if err := s.db.Update(func(txn kv.Txn) error {
+		if job.BookEvent != nil {
+			jobKey, err := txn.Get(uniqueKey(job.BookEvent.CredentialsID, job.BookEvent.EventID))
+			if err == nil {
+				value, err := txn.Get(jobKey)
+				if err != nil {
+					return fmt.Errorf("%q: %w", jobKey, err)
+				}
+				active = &Job{}
+				return json.Unmarshal(value, active)
+			} else if !errors.Is(err, kv.ErrNotFound) {
+				return err
+			}
+		}
+		return insertJob(txn, job)
+	}); err != nil {
+		return nil, err
+	}
+	return active, nil
+}
+
+func insertJob(txn kv.Txn, job *Job) error {
+	if err := deleteIndexKeys(txn, job.ID); err != nil {
+		return err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(idKey(job.ID), data); err != nil {
+		return err
+	}
+	if err := setIndexKeys(txn, job); err != nil {
+		return err
+	}
+	return setUniqueKey(txn, job)
+}
+
 var ErrNotFound = errors.New("not found")
 
-func ExcludeFailed() func(*Job) bool {
-	return func(job *Job) bool {
-		return len(job.Attempts) < MAX_ATTEMPTS
+// Filter selects jobs. If a filter is backed by an index, ListJobs uses index lookups instead of a full scan.
+type Filter struct {
+	// indexPrefixes are prefixes of index keys that together point to every job matching the filter.
+	indexPrefixes [][]byte
+	match         func(*Job) bool
+}
+
+// ExcludeFailed excludes jobs that did not succeed and will not run again.
+func ExcludeFailed() Filter {
+	return Filter{
+		match: func(job *Job) bool {
+			return job.Status != StatusMissed && (job.Status != StatusFailing || nextRetry(job) != nil)
+		},
 	}
 }
 
-func ExcludeSuccseeded() func(*Job) bool {
-	return func(job *Job) bool {
-		return job.Status != StatusSucceded
+func ExcludeSuccseeded() Filter {
+	return Filter{
+		match: func(job *Job) bool {
+			return job.Status != StatusSucceded
+		},
 	}
 }
 
-func BookEventsByCredentialsIDEventIDs(credentialsID string, eventIDs ...string) func(*Job) bool {
+func ByCredentialsID(credentialsID string) Filter {
+	return Filter{
+		indexPrefixes: [][]byte{credentialsIndexPrefix(credentialsID)},
+		match: func(job *Job) bool {
+			return job.BookEvent != nil && job.BookEvent.CredentialsID == credentialsID
+		},
+	}
+}
+
+// BookEventsByCredentialsIDEventIDs matches booking jobs of the credentials id for any of the events, either as
+// the event to book or as one of its alternatives.
+func BookEventsByCredentialsIDEventIDs(credentialsID string, eventIDs ...string) Filter {
 	eventIDsfilter := make(map[string]bool, len(eventIDs))
+	indexPrefixes := make([][]byte, 0, len(eventIDs))
 	for _, s := range eventIDs {
 		eventIDsfilter[s] = true
+		indexPrefixes = append(indexPrefixes, eventIndexPrefix(credentialsID, s))
 	}
-	return func(job *Job) bool {
-		if job.BookEvent == nil {
-			return false
-		}
-		if job.BookEvent.CredentialsID != credentialsID {
-			return false
-		}
-		return eventIDsfilter[job.BookEvent.EventID]
+	return Filter{
+		indexPrefixes: indexPrefixes,
+		match: func(job *Job) bool {
+			if job.BookEvent == nil {
+				return false
+			}
+			if job.BookEvent.CredentialsID != credentialsID {
+				return false
+			}
+			return slices.ContainsFunc(job.BookEvent.EventIDs(), func(eventID string) bool {
+				return eventIDsfilter[eventID]
+			})
+		},
 	}
 }
 
-func ByStatus(status ...Status) func(*Job) bool {
+func ByStatus(status ...Status) Filter {
 	filter := make(map[Status]bool, len(status))
+	indexPrefixes := make([][]byte, 0, len(status))
 	for _, s := range status {
 		filter[s] = true
+		indexPrefixes = append(indexPrefixes, statusIndexPrefix(s))
 	}
-	return func(job *Job) bool {
-		return filter[job.Status]
+	return Filter{
+		indexPrefixes: indexPrefixes,
+		match: func(job *Job) bool {
+			return filter[job.Status]
+		},
 	}
 }
 
 func (s *Store) FindByID(ctx context.Context, id string) (*Job, error) {
 	var job Job
-	if err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(idKey(id))
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(idKey(id))
 		if err != nil {
 			return err
 		}
-		return item.Value(func(value []byte) error {
-			return json.Unmarshal(value, &job)
-		})
+		return json.Unmarshal(value, &job)
 	}); err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
+		if errors.Is(err, kv.ErrNotFound) {
 			return nil, ErrNotFound
 		}
 		return nil, err
@@ -92,29 +173,60 @@ func (s *Store) FindByID(ctx context.Context, id string) (*Job, error) {
 	return &job, nil
 }
 
-func (s *Store) ListJobs(_ context.Context, filters ...func(*Job) bool) ([]*Job, error) {
+// ListJobs returns jobs matching all filters. Jobs are looked up with the first filter that has an index,
+// or with a full scan if there is none.
+func (s *Store) ListJobs(_ context.Context, filters ...Filter) ([]*Job, error) {
 	var jobs []*Job
-	if err := s.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		prefix := []byte("jobs/")
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			// only consider id keys
-			if len(bytes.Split(item.Key(), []byte("/"))) == 3 {
-				continue
+	matches := func(job *Job) bool {
+		for _, filter := range filters {
+			if !filter.match(job) {
+				return false
 			}
-			if err := item.Value(func(value []byte) error {
+		}
+		return true
+	}
+	var index *Filter
+	for i := range filters {
+		if filters[i].indexPrefixes != nil {
+			index = &filters[i]
+			break
+		}
+	}
+	if err := s.db.View(func(txn kv.Txn) error {
+		if index == nil {
+			return txn.Iterate([]byte("jobs/"), func(key []byte, value []byte) error {
+				// only consider id keys
+				if len(bytes.Split(key, []byte("/"))) == 3 {
+					return nil
+				}
 				job := &Job{}
 				if err := json.Unmarshal(value, &job); err != nil {
 					return err
 				}
-				for _, filter := range filters {
-					if !filter(job) {
-						return nil
-					}
+				if matches(job) {
+					jobs = append(jobs, job)
+				}
+				return nil
+			})
+		}
+		seen := map[string]bool{}
+		for _, prefix := range index.indexPrefixes {
+			if err := txn.Iterate(prefix, func(_ []byte, jobKey []byte) error {
+				if seen[string(jobKey)] {
+					return nil
+				}
+				seen[string(jobKey)] = true
+				value, err := txn.Get(jobKey)
+				if err != nil {
+					return fmt.Errorf("%q: %w", jobKey, err)
+				}
+				job := &Job{}
+				if err := json.Unmarshal(value, &job); err != nil {
+					return err
+				}
+				if matches(job) {
+					jobs = append(jobs, job)
 				}
-				jobs = append(jobs, job)
 				return nil
 			}); err != nil {
 				return err
@@ -128,7 +240,10 @@ func (s *Store) ListJobs(_ context.Context, filters ...func(*Job) bool) ([]*Job,
 }
 
 func (s *Store) DeleteJob(_ context.Context, id string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		if err := deleteIndexKeys(txn, id); err != nil {
+			return err
+		}
 		return txn.Delete(idKey(id))
 	})
 }
@@ -136,3 +251,90 @@ func (s *Store) DeleteJob(_ context.Context, id string) error {
 func idKey(id string) []byte {
 	return []byte(fmt.Sprintf("jobs/%s", id))
 }
+
+// uniqueKey points to the only active job booking the event for the credentials.
+func uniqueKey(credentialsID string, eventID string) []byte {
+	return []byte(fmt.Sprintf("jobs_unique/%s/%s", credentialsID, eventID))
+}
+
+func credentialsIndexPrefix(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("jobs_by_credentials/%s/", credentialsID))
+}
+
+func eventIndexPrefix(credentialsID string, eventID string) []byte {
+	return []byte(fmt.Sprintf("jobs_by_event/%s/%s/", credentialsID, eventID))
+}
+
+func statusIndexPrefix(status Status) []byte {
+	return []byte(fmt.Sprintf("jobs_by_status/%d/", status))
+}
+
+func indexKeys(job *Job) [][]byte {
+	keys := [][]byte{
+		append(statusIndexPrefix(job.Status), job.ID...),
+	}
+	if job.BookEvent != nil {
+		keys = append(keys,
+			append(credentialsIndexPrefix(job.BookEvent.CredentialsID), job.ID...),
+		)
+		for _, eventID := range job.BookEvent.EventIDs() {
+			keys = append(keys, append(eventIndexPrefix(job.BookEvent.CredentialsID, eventID), job.ID...))
+		}
+	}
+	return keys
+}
+
+func setIndexKeys(txn kv.Txn, job *Job) error {
+	for _, key := range indexKeys(job) {
+		if err := txn.Set(key, idKey(job.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUniqueKey sets the unique key for the job while it's active.
+func setUniqueKey(txn kv.Txn, job *Job) error {
+	if job.BookEvent == nil || isFinished(job) {
+		return nil
+	}
+	return txn.Set(uniqueKey(job.BookEvent.CredentialsID, job.BookEvent.EventID), idKey(job.ID))
+}
+
+// deleteUniqueKey deletes the unique key if it points to the job.
+func deleteUniqueKey(txn kv.Txn, job *Job) error {
+	if job.BookEvent == nil {
+		return nil
+	}
+	key := uniqueKey(job.BookEvent.CredentialsID, job.BookEvent.EventID)
+	value, err := txn.Get(key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !bytes.Equal(value, idKey(job.ID)) {
+		return nil
+	}
+	return txn.Delete(key)
+}
+
+// deleteIndexKeys deletes index and unique keys of a currently stored job with the id, if any.
+func deleteIndexKeys(txn kv.Txn, id string) error {
+	value, err := txn.Get(idKey(id))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(value, &job); err != nil {
+		return err
+	}
+	for _, key := range indexKeys(job) {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return deleteUniqueKey(txn, job)
+}
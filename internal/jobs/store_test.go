@@ -0,0 +1,188 @@
+package jobs
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+func ids(jobs []*Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestListJobs(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	for _, job := range []*Job{
+		{ID: "1", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}},
+		{ID: "2", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "y"}},
+		{ID: "3", Status: StatusSucceded, BookEvent: &BookEventJob{CredentialsID: "b", EventID: "x"}},
+		{ID: "4", Status: StatusMissed, BookEvent: &BookEventJob{CredentialsID: "c", EventID: "x"}},
+		{ID: "5", Status: StatusFailing, Attempts: []*Attempt{{Error: "full"}}, BookEvent: &BookEventJob{CredentialsID: "c", EventID: "y"}},
+		{ID: "6", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "w", Alternatives: []string{"z"}}},
+	} {
+		if err := store.InsertJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		filters  []Filter
+		expected []string
+	}{
+		"all":                {nil, []string{"1", "2", "3", "4", "5", "6"}},
+		"by credentials":     {[]Filter{ByCredentialsID("a")}, []string{"1", "2", "6"}},
+		"by event":           {[]Filter{BookEventsByCredentialsIDEventIDs("a", "x", "y")}, []string{"1", "2"}},
+		"by status":          {[]Filter{ByStatus(StatusSucceded)}, []string{"3"}},
+		"by alternative":     {[]Filter{BookEventsByCredentialsIDEventIDs("a", "z")}, []string{"6"}},
+		"by multiple status": {[]Filter{ByStatus(StatusPending, StatusSucceded)}, []string{"1", "2", "3", "6"}},
+		"combined":           {[]Filter{ByCredentialsID("b"), ExcludeSuccseeded()}, []string{}},
+		"exclude failed":     {[]Filter{ByCredentialsID("c"), ExcludeFailed()}, []string{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			jobs, err := store.ListJobs(ctx, tc.filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(jobs); !slices.Equal(got, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestListJobsUpdatedStatus(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	job := &Job{ID: "1", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}}
+	if err := store.InsertJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.Status = StatusSucceded
+	if err := store.InsertJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := store.ListJobs(ctx, ByStatus(StatusPending))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending jobs, got %v", ids(pending))
+	}
+
+	succeeded, err := store.ListJobs(ctx, ByStatus(StatusSucceded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(succeeded); !slices.Equal(got, []string{"1"}) {
+		t.Fatalf("expected [1], got %v", got)
+	}
+
+	if err := store.DeleteJob(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	byCredentials, err := store.ListJobs(ctx, ByCredentialsID("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byCredentials) != 0 {
+		t.Fatalf("expected no jobs, got %v", ids(byCredentials))
+	}
+}
+
+func TestListJobsRemovedAlternative(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	job := &Job{ID: "1", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x", Alternatives: []string{"y"}}}
+	if err := store.InsertJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.BookEvent.Alternatives = nil
+	if err := store.InsertJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.ListJobs(ctx, BookEventsByCredentialsIDEventIDs("a", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("expected no jobs, got %v", ids(found))
+	}
+}
+
+func TestInsertUniqueJob(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	first := &Job{ID: "1", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}}
+	scheduled, err := store.InsertUniqueJob(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "1" {
+		t.Fatalf("expected job 1, got %s", scheduled.ID)
+	}
+
+	// same event is not scheduled twice
+	scheduled, err = store.InsertUniqueJob(ctx, &Job{ID: "2", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "1" {
+		t.Fatalf("expected existing job 1, got %s", scheduled.ID)
+	}
+
+	// other credentials can book the same event
+	scheduled, err = store.InsertUniqueJob(ctx, &Job{ID: "3", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "b", EventID: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "3" {
+		t.Fatalf("expected job 3, got %s", scheduled.ID)
+	}
+
+	// finished job no longer blocks scheduling
+	first.Status = StatusSucceded
+	if err := store.InsertJob(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err = store.InsertUniqueJob(ctx, &Job{ID: "4", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "4" {
+		t.Fatalf("expected job 4, got %s", scheduled.ID)
+	}
+
+	// deleted job no longer blocks scheduling
+	if err := store.DeleteJob(ctx, "4"); err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err = store.InsertUniqueJob(ctx, &Job{ID: "5", Status: StatusPending, BookEvent: &BookEventJob{CredentialsID: "a", EventID: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "5" {
+		t.Fatalf("expected job 5, got %s", scheduled.ID)
+	}
+
+	jobs, err := store.ListJobs(ctx, ByCredentialsID("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(jobs); !slices.Equal(got, []string{"1", "5"}) {
+		t.Fatalf("expected [1 5], got %v", got)
+	}
+}
@@ -1,12 +1,17 @@
 package keys
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+
+	"golang.org/x/crypto/scrypt"
 )
 
 var keySize = 32 // 32 bytes for AES-256
@@ -22,7 +27,52 @@ func NewKey() (*Key, error) {
 	return &key, nil
 }
 
-func ParseKey(bytes []byte) (*Key, error) {
+var ErrSaltRequired = errors.New("salt is required")
+
+// ParseKey parses a key from one of the formats:
+//
+//   - base64:<base64 encoded key>
+//   - hex:<hex encoded key>
+//   - passphrase:<any passphrase>, derived with scrypt using salt
+//   - raw key of 16, 24 or 32 bytes
+func ParseKey(value []byte, salt []byte) (*Key, error) {
+	if encoded, ok := bytes.CutPrefix(value, []byte("base64:")); ok {
+		decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+		if err != nil {
+			// String() encodes keys with url encoding
+			decoded, err = base64.URLEncoding.DecodeString(string(encoded))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("base64: %w", err)
+		}
+		return newKey(decoded)
+	}
+	if encoded, ok := bytes.CutPrefix(value, []byte("hex:")); ok {
+		decoded, err := hex.DecodeString(string(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("hex: %w", err)
+		}
+		return newKey(decoded)
+	}
+	if passphrase, ok := bytes.CutPrefix(value, []byte("passphrase:")); ok {
+		return DeriveKey(passphrase, salt)
+	}
+	return newKey(value)
+}
+
+// DeriveKey derives a key from a passphrase of any length using scrypt.
+func DeriveKey(passphrase []byte, salt []byte) (*Key, error) {
+	if len(salt) == 0 {
+		return nil, ErrSaltRequired
+	}
+	derived, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt: %w", err)
+	}
+	return newKey(derived)
+}
+
+func newKey(bytes []byte) (*Key, error) {
 	switch len(bytes) {
 	case 16, 24, 32:
 		key := Key(bytes)
@@ -73,3 +123,44 @@ func (k Key) Decrypt(ciphertext []byte) ([]byte, error) {
 
 	return ciphertext, nil
 }
+
+// NewEncryptWriter returns a writer that encrypts data written to it into w.
+// Output has the same format as Encrypt, so it can be decrypted with Decrypt or NewDecryptReader.
+func (k Key) NewEncryptWriter(w io.Writer) (io.Writer, error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(iv); err != nil {
+		return nil, err
+	}
+
+	return &cipher.StreamWriter{
+		S: cipher.NewCFBEncrypter(block, iv),
+		W: w,
+	}, nil
+}
+
+// NewDecryptReader returns a reader that decrypts data read from r.
+func (k Key) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(r, iv); err != nil {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	return &cipher.StreamReader{
+		S: cipher.NewCFBDecrypter(block, iv),
+		R: r,
+	}, nil
+}
@@ -1,6 +1,11 @@
 package keys
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
 
 func Test(t *testing.T) {
 	key, err := NewKey()
@@ -24,3 +29,163 @@ func Test(t *testing.T) {
 		t.Fatal("encrypted != decrypted")
 	}
 }
+
+func TestKeyring(t *testing.T) {
+	keyring, err := ParseKeyring("new:0123456789abcdef,old:fedcba9876543210", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keyring.ActiveID() != "new" {
+		t.Fatalf("expected active key \"new\", got %q", keyring.ActiveID())
+	}
+
+	plaintext := "Hello, World!"
+
+	keyID, encrypted, err := keyring.Encrypt([]byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keyID != "new" {
+		t.Fatalf("expected key id \"new\", got %q", keyID)
+	}
+
+	decrypted, err := keyring.Decrypt(keyID, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != string(decrypted) {
+		t.Fatal("encrypted != decrypted")
+	}
+}
+
+func TestKeyringDefaultKey(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := "Hello, World!"
+
+	encrypted, err := key.Encrypt([]byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := NewKeyring(DefaultKeyID, key)
+
+	decrypted, err := keyring.Decrypt("", encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != string(decrypted) {
+		t.Fatal("encrypted != decrypted")
+	}
+}
+
+func TestKeyringUnknownKey(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := NewKeyring("active", key)
+
+	if _, err := keyring.Decrypt("retired", []byte{}); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected %q, got %q", ErrUnknownKey, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	salt := []byte("salt")
+	for name, value := range map[string]string{
+		"raw":    "0123456789abcdef",
+		"base64": "base64:MDEyMzQ1Njc4OWFiY2RlZg==",
+		"hex":    "hex:30313233343536373839616263646566",
+	} {
+		t.Run(name, func(t *testing.T) {
+			key, err := ParseKey([]byte(value), salt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(*key) != "0123456789abcdef" {
+				t.Fatalf("expected \"0123456789abcdef\", got %q", string(*key))
+			}
+		})
+	}
+}
+
+func TestParseKeyPassphrase(t *testing.T) {
+	first, err := ParseKey([]byte("passphrase:correct horse battery staple"), []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*first) != keySize {
+		t.Fatalf("expected key of %d bytes, got %d", keySize, len(*first))
+	}
+
+	second, err := ParseKey([]byte("passphrase:correct horse battery staple"), []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.String() != second.String() {
+		t.Fatal("same passphrase and salt derived different keys")
+	}
+
+	if _, err := ParseKey([]byte("passphrase:correct horse battery staple"), nil); !errors.Is(err, ErrSaltRequired) {
+		t.Fatalf("expected %q, got %q", ErrSaltRequired, err)
+	}
+}
+
+func TestParseKeyInvalidSize(t *testing.T) {
+	if _, err := ParseKey([]byte("too short"), nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestStream(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := "Hello, World!"
+
+	encrypted := &bytes.Buffer{}
+	w, err := key.NewEncryptWriter(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := key.NewDecryptReader(bytes.NewReader(encrypted.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != string(decrypted) {
+		t.Fatal("encrypted != decrypted")
+	}
+
+	decrypted, err = key.Decrypt(encrypted.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != string(decrypted) {
+		t.Fatal("encrypted != decrypted")
+	}
+}
@@ -0,0 +1,100 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultKeyID is an id of the key that was used before keyring was introduced.
+// Ciphertexts without a key id are decrypted with it.
+const DefaultKeyID = "default"
+
+var ErrUnknownKey = errors.New("unknown key")
+
+// Keyring is a set of keys identified by ids. Active key is used to encrypt
+// new data, any key can be used to decrypt.
+type Keyring struct {
+	activeID string
+	keys     map[string]*Key
+}
+
+func NewKeyring(activeID string, active *Key) *Keyring {
+	return &Keyring{
+		activeID: activeID,
+		keys: map[string]*Key{
+			activeID: active,
+		},
+	}
+}
+
+// ParseKeyring parses a comma separated list of id:key pairs, for example "2024:key1,default:key2".
+// The first key in the list is the active one. See ParseKey for supported key formats.
+func ParseKeyring(value string, salt []byte) (*Keyring, error) {
+	var keyring *Keyring
+	for _, pair := range strings.Split(value, ",") {
+		id, rawKey, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("%q: expected id:key", pair)
+		}
+		key, err := ParseKey([]byte(rawKey), salt)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if keyring == nil {
+			keyring = NewKeyring(id, key)
+			continue
+		}
+		if err := keyring.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+// ParseKeyringOrKey parses the keyring if it is set, otherwise it returns a keyring of the single key with the default
+// id. It's used to accept both of the --encryption-key and --encryption-keys flags.
+func ParseKeyringOrKey(key string, keyring string, salt []byte) (*Keyring, error) {
+	if keyring != "" {
+		return ParseKeyring(keyring, salt)
+	}
+	encryptionKey, err := ParseKey([]byte(key), salt)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(DefaultKeyID, encryptionKey), nil
+}
+
+// Add adds a key to the keyring. Added key can only be used for decryption.
+func (k *Keyring) Add(id string, key *Key) error {
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key %q: duplicate id", id)
+	}
+	k.keys[id] = key
+	return nil
+}
+
+func (k *Keyring) ActiveID() string {
+	return k.activeID
+}
+
+// Encrypt encrypts data with the active key, and returns id of the key together with ciphertext.
+func (k *Keyring) Encrypt(data []byte) (string, []byte, error) {
+	ciphertext, err := k.keys[k.activeID].Encrypt(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.activeID, ciphertext, nil
+}
+
+// Decrypt decrypts ciphertext with a key identified by keyID. Empty keyID means DefaultKeyID.
+func (k *Keyring) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	if keyID == "" {
+		keyID = DefaultKeyID
+	}
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", keyID, ErrUnknownKey)
+	}
+	return key.Decrypt(ciphertext)
+}
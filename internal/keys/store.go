@@ -0,0 +1,44 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+var saltSize = 16
+
+// Store keeps salt that is used to derive keys from passphrases.
+type Store struct {
+	db kv.Store
+}
+
+func NewStore(db kv.Store) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// GetOrCreateSalt returns a salt, generating and storing a new one on the first call.
+func (s *Store) GetOrCreateSalt(ctx context.Context) ([]byte, error) {
+	var salt []byte
+	if err := s.db.Update(func(txn kv.Txn) error {
+		var err error
+		salt, err = txn.Get(saltKey)
+		if errors.Is(err, kv.ErrNotFound) {
+			salt = make([]byte, saltSize)
+			if _, err := rand.Read(salt); err != nil {
+				return err
+			}
+			return txn.Set(saltKey, salt)
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+var saltKey = []byte("keys/salt")
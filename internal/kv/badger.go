@@ -0,0 +1,80 @@
+package kv
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var _ Store = &Badger{}
+
+type Badger struct {
+	db *badger.DB
+}
+
+func NewBadger(db *badger.DB) *Badger {
+	return &Badger{
+		db: db,
+	}
+}
+
+func (b *Badger) View(fn func(Txn) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn: txn})
+	})
+}
+
+func (b *Badger) Update(fn func(Txn) error) error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn: txn})
+	})
+}
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t *badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t *badgerTxn) Set(key []byte, value []byte) error {
+	return badgerError(t.txn.Set(key, value))
+}
+
+func (t *badgerTxn) Delete(key []byte) error {
+	return badgerError(t.txn.Delete(key))
+}
+
+func (t *badgerTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	it := t.txn.NewIterator(badger.IteratorOptions{
+		PrefetchValues: true,
+		PrefetchSize:   100,
+		Prefix:         prefix,
+	})
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(item.KeyCopy(nil), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func badgerError(err error) error {
+	if errors.Is(err, badger.ErrReadOnlyTxn) {
+		return ErrReadOnly
+	}
+	return err
+}
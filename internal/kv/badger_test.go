@@ -0,0 +1,20 @@
+package kv_test
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/kv/kvtest"
+)
+
+func TestBadger(t *testing.T) {
+	kvtest.Run(t, func(t *testing.T) kv.Store {
+		db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return kv.NewBadger(db)
+	})
+}
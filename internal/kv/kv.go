@@ -0,0 +1,27 @@
+package kv
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrReadOnly = errors.New("read-only transaction")
+)
+
+// Store is a transactional key-value store.
+type Store interface {
+	// View runs fn in a read-only transaction.
+	View(fn func(Txn) error) error
+	// Update runs fn in a read-write transaction. Changes are committed if fn returns nil,
+	// and discarded otherwise.
+	Update(fn func(Txn) error) error
+}
+
+type Txn interface {
+	// Get returns value of the key, or ErrNotFound.
+	Get(key []byte) ([]byte, error)
+	Set(key []byte, value []byte) error
+	Delete(key []byte) error
+	// Iterate calls fn for every key with prefix in lexicographical order, until fn returns an error.
+	// Keys and values are safe to retain, and the transaction can be modified during iteration.
+	Iterate(prefix []byte, fn func(key []byte, value []byte) error) error
+}
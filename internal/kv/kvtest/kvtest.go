@@ -0,0 +1,224 @@
+// Package kvtest contains a conformance test suite for kv.Store implementations.
+package kvtest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+// Run runs the conformance test suite against stores created by newStore.
+func Run(t *testing.T, newStore func(t *testing.T) kv.Store) {
+	t.Run("get not found", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.View(func(txn kv.Txn) error {
+			_, err := txn.Get([]byte("missing"))
+			return err
+		}); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("expected %q, got %q", kv.ErrNotFound, err)
+		}
+	})
+
+	t.Run("set and get", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "key", "value")
+		if value := get(t, store, "key"); value != "value" {
+			t.Fatalf("expected \"value\", got %q", value)
+		}
+	})
+
+	t.Run("get within transaction", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Update(func(txn kv.Txn) error {
+			if err := txn.Set([]byte("key"), []byte("value")); err != nil {
+				return err
+			}
+			value, err := txn.Get([]byte("key"))
+			if err != nil {
+				return err
+			}
+			if string(value) != "value" {
+				t.Fatalf("expected \"value\", got %q", value)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "key", "value")
+		if err := store.Update(func(txn kv.Txn) error {
+			return txn.Delete([]byte("key"))
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.View(func(txn kv.Txn) error {
+			_, err := txn.Get([]byte("key"))
+			return err
+		}); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("expected %q, got %q", kv.ErrNotFound, err)
+		}
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "key", "value")
+		rollback := errors.New("rollback")
+		if err := store.Update(func(txn kv.Txn) error {
+			if err := txn.Set([]byte("key"), []byte("updated")); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte("other"), []byte("value")); err != nil {
+				return err
+			}
+			return rollback
+		}); !errors.Is(err, rollback) {
+			t.Fatalf("expected %q, got %q", rollback, err)
+		}
+		if value := get(t, store, "key"); value != "value" {
+			t.Fatalf("expected \"value\", got %q", value)
+		}
+		if err := store.View(func(txn kv.Txn) error {
+			_, err := txn.Get([]byte("other"))
+			return err
+		}); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("expected %q, got %q", kv.ErrNotFound, err)
+		}
+	})
+
+	t.Run("read-only view", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.View(func(txn kv.Txn) error {
+			return txn.Set([]byte("key"), []byte("value"))
+		}); !errors.Is(err, kv.ErrReadOnly) {
+			t.Fatalf("expected %q, got %q", kv.ErrReadOnly, err)
+		}
+		if err := store.View(func(txn kv.Txn) error {
+			return txn.Delete([]byte("key"))
+		}); !errors.Is(err, kv.ErrReadOnly) {
+			t.Fatalf("expected %q, got %q", kv.ErrReadOnly, err)
+		}
+	})
+
+	t.Run("iterate prefix in order", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "b/2", "4")
+		set(t, store, "a/2", "2")
+		set(t, store, "a/1", "1")
+		set(t, store, "a", "0")
+		set(t, store, "b/1", "3")
+		keys, values := iterate(t, store, "a/")
+		if !slices.Equal(keys, []string{"a/1", "a/2"}) {
+			t.Fatalf("expected [a/1 a/2], got %v", keys)
+		}
+		if !slices.Equal(values, []string{"1", "2"}) {
+			t.Fatalf("expected [1 2], got %v", values)
+		}
+	})
+
+	t.Run("iterate sees pending writes", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "a/1", "1")
+		set(t, store, "a/2", "2")
+		keys := []string{}
+		if err := store.Update(func(txn kv.Txn) error {
+			if err := txn.Set([]byte("a/3"), []byte("3")); err != nil {
+				return err
+			}
+			if err := txn.Delete([]byte("a/1")); err != nil {
+				return err
+			}
+			return txn.Iterate([]byte("a/"), func(key []byte, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(keys, []string{"a/2", "a/3"}) {
+			t.Fatalf("expected [a/2 a/3], got %v", keys)
+		}
+	})
+
+	t.Run("modify during iteration", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "a/1", "1")
+		set(t, store, "a/2", "2")
+		if err := store.Update(func(txn kv.Txn) error {
+			return txn.Iterate([]byte("a/"), func(key []byte, value []byte) error {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+				return txn.Set(append([]byte("b/"), key...), value)
+			})
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if keys, _ := iterate(t, store, "a/"); len(keys) != 0 {
+			t.Fatalf("expected no keys, got %v", keys)
+		}
+		if keys, _ := iterate(t, store, "b/"); !slices.Equal(keys, []string{"b/a/1", "b/a/2"}) {
+			t.Fatalf("expected [b/a/1 b/a/2], got %v", keys)
+		}
+	})
+
+	t.Run("iterate stops on error", func(t *testing.T) {
+		store := newStore(t)
+		set(t, store, "a/1", "1")
+		set(t, store, "a/2", "2")
+		stop := errors.New("stop")
+		calls := 0
+		if err := store.View(func(txn kv.Txn) error {
+			return txn.Iterate([]byte("a/"), func(key []byte, value []byte) error {
+				calls++
+				return stop
+			})
+		}); !errors.Is(err, stop) {
+			t.Fatalf("expected %q, got %q", stop, err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	})
+}
+
+func set(t *testing.T, store kv.Store, key string, value string) {
+	t.Helper()
+	if err := store.Update(func(txn kv.Txn) error {
+		return txn.Set([]byte(key), []byte(value))
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, store kv.Store, key string) string {
+	t.Helper()
+	var value []byte
+	if err := store.View(func(txn kv.Txn) error {
+		var err error
+		value, err = txn.Get([]byte(key))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return string(value)
+}
+
+func iterate(t *testing.T, store kv.Store, prefix string) ([]string, []string) {
+	t.Helper()
+	keys, values := []string{}, []string{}
+	if err := store.View(func(txn kv.Txn) error {
+		return txn.Iterate([]byte(prefix), func(key []byte, value []byte) error {
+			keys = append(keys, string(key))
+			values = append(values, string(value))
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return keys, values
+}
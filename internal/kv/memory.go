@@ -0,0 +1,110 @@
+package kv
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+)
+
+var _ Store = &Memory{}
+
+// Memory is an in-memory store. Transactions are serialized.
+type Memory struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		data: make(map[string][]byte),
+	}
+}
+
+func (m *Memory) View(fn func(Txn) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fn(&memoryTxn{memory: m, readOnly: true})
+}
+
+func (m *Memory) Update(fn func(Txn) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txn := &memoryTxn{memory: m, writes: make(map[string][]byte)}
+	if err := fn(txn); err != nil {
+		return err
+	}
+	for key, value := range txn.writes {
+		if value == nil {
+			delete(m.data, key)
+		} else {
+			m.data[key] = value
+		}
+	}
+	return nil
+}
+
+type memoryTxn struct {
+	memory   *Memory
+	readOnly bool
+	// writes contains pending changes, nil value means the key is deleted.
+	writes map[string][]byte
+}
+
+func (t *memoryTxn) Get(key []byte) ([]byte, error) {
+	value, ok := t.writes[string(key)]
+	if !ok {
+		value, ok = t.memory.data[string(key)]
+	}
+	if !ok || value == nil {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(value), nil
+}
+
+func (t *memoryTxn) Set(key []byte, value []byte) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	t.writes[string(key)] = bytes.Clone(value)
+	return nil
+}
+
+func (t *memoryTxn) Delete(key []byte) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	t.writes[string(key)] = nil
+	return nil
+}
+
+func (t *memoryTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	keys := []string{}
+	for key := range t.memory.data {
+		if _, ok := t.writes[key]; !ok && bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for key, value := range t.writes {
+		if value != nil && bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := t.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+	for i, key := range keys {
+		if err := fn([]byte(key), values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
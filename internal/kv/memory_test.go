@@ -0,0 +1,14 @@
+package kv_test
+
+import (
+	"testing"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/kv/kvtest"
+)
+
+func TestMemory(t *testing.T) {
+	kvtest.Run(t, func(t *testing.T) kv.Store {
+		return kv.NewMemory()
+	})
+}
@@ -0,0 +1,202 @@
+package leases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLost is returned when the lease was taken over by another holder.
+var ErrLost = errors.New("lease lost")
+
+// Record is a lease as stored in the lease file.
+type Record struct {
+	Holder string `json:"holder"`
+	// Address is where the holder serves http, used by other instances to forward requests to it.
+	Address string    `json:"address,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+func (r Record) Expired(now time.Time) bool {
+	return !now.Before(r.Expires)
+}
+
+// Lease is a time limited lease stored in a file, shared by all instances that use the same database.
+// Only one holder can hold the lease at a time. The holder must renew the lease before it expires.
+type Lease struct {
+	path    string
+	holder  string
+	address string
+	ttl     time.Duration
+
+	// renewedAt is when the lease was last acquired or renewed by this holder.
+	renewedAt time.Time
+}
+
+func New(
+	path string,
+	holder string,
+	address string,
+	ttl time.Duration,
+) *Lease {
+	return &Lease{
+		path:    path,
+		holder:  holder,
+		address: address,
+		ttl:     ttl,
+	}
+}
+
+// Current returns the current lease, or nil if nobody ever held it.
+func (l *Lease) Current() (*Record, error) {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	record := &Record{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("%s: %w", l.path, err)
+	}
+	return record, nil
+}
+
+// TryAcquire acquires the lease if it's free or expired, or renews it if it's already held.
+// Returns the current lease and whether it's held by this holder.
+func (l *Lease) TryAcquire() (*Record, bool, error) {
+	var record *Record
+	held := false
+	if err := l.withLock(func() error {
+		current, err := l.Current()
+		if err != nil {
+			return err
+		}
+		if current != nil && current.Holder != l.holder && !current.Expired(time.Now()) {
+			record = current
+			return nil
+		}
+		now := time.Now()
+		record = &Record{
+			Holder:  l.holder,
+			Address: l.address,
+			Expires: now.Add(l.ttl),
+		}
+		if err := l.write(record); err != nil {
+			return err
+		}
+		l.renewedAt = now
+		held = true
+		return nil
+	}); err != nil {
+		return nil, false, err
+	}
+	return record, held, nil
+}
+
+// Acquire blocks until the lease is acquired or context is cancelled.
+func (l *Lease) Acquire(ctx context.Context) error {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		record, held, err := l.TryAcquire()
+		if err != nil {
+			slog.ErrorContext(ctx, "acquire lease", "error", err)
+		} else if held {
+			slog.InfoContext(ctx, "lease acquired", "holder", l.holder)
+			return nil
+		} else {
+			slog.DebugContext(ctx, "lease is held", "holder", record.Holder, "expires", record.Expires)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Keep renews the lease until context is cancelled. Returns ErrLost if the lease was taken over, which
+// happens if it was not renewed in time, or if it could not be renewed for longer than its ttl, so it might have been
+// taken over already.
+func (l *Lease) Keep(ctx context.Context) error {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			record, held, err := l.TryAcquire()
+			if err != nil {
+				slog.ErrorContext(ctx, "renew lease", "error", err)
+				if time.Since(l.renewedAt) >= l.ttl {
+					return fmt.Errorf("%w: not renewed since %s: %w", ErrLost, l.renewedAt.Format(time.RFC3339), err)
+				}
+				continue
+			}
+			if !held {
+				return fmt.Errorf("%w to %q", ErrLost, record.Holder)
+			}
+		}
+	}
+}
+
+// Release releases the lease if it's held by this holder, so that another instance can take over
+// without waiting for it to expire.
+func (l *Lease) Release() error {
+	return l.withLock(func() error {
+		current, err := l.Current()
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Holder != l.holder {
+			return nil
+		}
+		if err := os.Remove(l.path); err != nil {
+			return err
+		}
+		slog.Info("lease released", "holder", l.holder)
+		return nil
+	})
+}
+
+// write replaces the lease file, so that readers never see a partially written lease.
+func (l *Lease) write(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), l.path)
+}
+
+// withLock runs fn while holding an exclusive lock on the lease lock file.
+func (l *Lease) withLock(fn func() error) error {
+	file, err := os.OpenFile(l.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("open lock file: %w", err)
+	}
+	defer file.Close()
+	if err := lock(file); err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	defer unlock(file)
+	return fn()
+}
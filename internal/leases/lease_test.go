@@ -0,0 +1,99 @@
+package leases
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease")
+	first := New(path, "first", "http://first", time.Minute)
+	second := New(path, "second", "http://second", time.Minute)
+
+	if _, held, err := first.TryAcquire(); err != nil {
+		t.Fatal(err)
+	} else if !held {
+		t.Fatal("expected first to acquire free lease")
+	}
+
+	record, held, err := second.TryAcquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held {
+		t.Fatal("expected second not to acquire held lease")
+	}
+	if record.Holder != "first" || record.Address != "http://first" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+
+	// renew
+	if _, held, err := first.TryAcquire(); err != nil {
+		t.Fatal(err)
+	} else if !held {
+		t.Fatal("expected first to renew its lease")
+	}
+
+	if err := second.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := first.Current(); err != nil {
+		t.Fatal(err)
+	} else if current == nil || current.Holder != "first" {
+		t.Fatalf("expected release by other holder to be a no-op, got %+v", current)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, held, err := second.TryAcquire(); err != nil {
+		t.Fatal(err)
+	} else if !held {
+		t.Fatal("expected second to acquire released lease")
+	}
+}
+
+func TestTryAcquireExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease")
+	first := New(path, "first", "", -time.Second)
+	second := New(path, "second", "", time.Minute)
+
+	if _, held, err := first.TryAcquire(); err != nil {
+		t.Fatal(err)
+	} else if !held {
+		t.Fatal("expected first to acquire free lease")
+	}
+	if _, held, err := second.TryAcquire(); err != nil {
+		t.Fatal(err)
+	} else if !held {
+		t.Fatal("expected second to acquire expired lease")
+	}
+}
+
+func TestKeepFailingRenewal(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	lease := New(filepath.Join(dir, "lease"), "first", "", 30*time.Millisecond)
+	if _, held, err := lease.TryAcquire(); err != nil {
+		t.Fatal(err)
+	} else if !held {
+		t.Fatal("expected to acquire free lease")
+	}
+
+	// renewals fail from now on
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := lease.Keep(ctx); !errors.Is(err, ErrLost) {
+		t.Fatalf("expected %v, got %v", ErrLost, err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix || solaris
+
+package leases
+
+import (
+	"os"
+	"sync"
+)
+
+// fileLocks is used instead of file locks, which are not supported on this platform. It only excludes holders in the
+// same process, so only a single instance must run.
+var fileLocks sync.Mutex
+
+func lock(file *os.File) error {
+	fileLocks.Lock()
+	return nil
+}
+
+func unlock(file *os.File) error {
+	fileLocks.Unlock()
+	return nil
+}
@@ -0,0 +1,159 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+// gcDiscardRatio is passed to badger.DB.RunValueLogGC. A value log file is rewritten if at least
+// this ratio of it can be discarded.
+const gcDiscardRatio = 0.5
+
+// Report describes what a single maintenance run did.
+type Report struct {
+	DeletedTokens int
+	ArchivedJobs  int
+	// ReclaimedBytes is zero if the database did not shrink, gc and compactions can also grow the value log.
+	ReclaimedBytes int64
+}
+
+// Service periodically removes data that is not needed anymore.
+type Service struct {
+	db              *badger.DB
+	tokensStore     *tokens.Store
+	jobsStore       *jobs.Store
+	interval        time.Duration
+	tokensRetention time.Duration
+	jobsRetention   time.Duration
+}
+
+func NewService(
+	db *badger.DB,
+	tokensStore *tokens.Store,
+	jobsStore *jobs.Store,
+	interval time.Duration,
+	tokensRetention time.Duration,
+	jobsRetention time.Duration,
+) *Service {
+	return &Service{
+		db:              db,
+		tokensStore:     tokensStore,
+		jobsStore:       jobsStore,
+		interval:        interval,
+		tokensRetention: tokensRetention,
+		jobsRetention:   jobsRetention,
+	}
+}
+
+// Run runs maintenance every interval until context is cancelled.
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			report, err := s.Maintain(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "maintenance", "error", err)
+				continue
+			}
+			slog.InfoContext(ctx, "maintenance done",
+				"deleted_tokens", report.DeletedTokens,
+				"archived_jobs", report.ArchivedJobs,
+				"reclaimed_bytes", report.ReclaimedBytes,
+			)
+		}
+	}
+}
+
+// Maintain deletes expired tokens, archives finished jobs older than retention and garbage collects
+// value logs.
+func (s *Service) Maintain(ctx context.Context) (*Report, error) {
+	sizeBefore, err := s.size()
+	if err != nil {
+		return nil, fmt.Errorf("size: %w", err)
+	}
+
+	deletedTokens, err := s.tokensStore.DeleteExpired(ctx, time.Now().Add(-s.tokensRetention))
+	if err != nil {
+		return nil, fmt.Errorf("delete expired tokens: %w", err)
+	}
+
+	archivedJobs, err := s.jobsStore.Archive(ctx, time.Now().Add(-s.jobsRetention))
+	if err != nil {
+		return nil, fmt.Errorf("archive jobs: %w", err)
+	}
+
+	if err := s.collectGarbage(ctx); err != nil {
+		return nil, fmt.Errorf("value log gc: %w", err)
+	}
+
+	sizeAfter, err := s.size()
+	if err != nil {
+		return nil, fmt.Errorf("size: %w", err)
+	}
+	return &Report{
+		DeletedTokens:  deletedTokens,
+		ArchivedJobs:   archivedJobs,
+		ReclaimedBytes: max(sizeBefore-sizeAfter, 0),
+	}, nil
+}
+
+// collectGarbage rewrites value log files until there is nothing left to rewrite.
+func (s *Service) collectGarbage(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := s.db.RunValueLogGC(gcDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// size returns total size of database files on disk. badger.DB.Size is only refreshed periodically,
+// so files are measured directly.
+func (s *Service) size() (int64, error) {
+	opts := s.db.Opts()
+	if opts.InMemory {
+		return 0, nil
+	}
+	dirs := []string{opts.Dir}
+	if opts.ValueDir != opts.Dir {
+		dirs = append(dirs, opts.ValueDir)
+	}
+	var size int64
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if errors.Is(err, os.ErrNotExist) {
+				// removed by compaction or gc in the meantime
+				continue
+			} else if err != nil {
+				return 0, err
+			}
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,59 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+func TestMaintain(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions(t.TempDir()).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := kv.NewBadger(db)
+
+	key, err := keys.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokensStore := tokens.NewStore(store, keys.NewKeyring(keys.DefaultKeyID, key))
+	jobsStore := jobs.NewStore(store)
+
+	ctx := context.Background()
+	if err := tokensStore.Insert(ctx, &tokens.Token{
+		CredentialsID: "id",
+		Token:         "token",
+		Expires:       time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobsStore.InsertJob(ctx, &jobs.Job{
+		ID:        "id",
+		Time:      time.Now().Add(-48 * time.Hour),
+		Status:    jobs.StatusSucceded,
+		Attempts:  []*jobs.Attempt{{StartedAt: time.Now().Add(-48 * time.Hour)}},
+		BookEvent: &jobs.BookEventJob{CredentialsID: "id", EventID: "event"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(db, tokensStore, jobsStore, time.Hour, 0, 24*time.Hour)
+	report, err := service.Maintain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DeletedTokens != 1 {
+		t.Errorf("expected 1 deleted token, got %d", report.DeletedTokens)
+	}
+	if report.ArchivedJobs != 1 {
+		t.Errorf("expected 1 archived job, got %d", report.ArchivedJobs)
+	}
+}
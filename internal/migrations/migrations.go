@@ -2,39 +2,314 @@ package migrations
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
-	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
-func Run(db *badger.DB) error {
-	if err := renameCredentialsLoginsKey(db); err != nil {
-		return fmt.Errorf("rename credentials logins key: %w", err)
+type Migration struct {
+	ID   int
+	Name string
+	Up   func(txn kv.Txn) error
+}
+
+// registry contains all migrations ordered by id. New migrations must be appended with the next id,
+// existing migrations must never change.
+var registry = []Migration{
+	{ID: 1, Name: "rename credentials logins key", Up: renameCredentialsLoginsKey},
+	{ID: 2, Name: "backfill jobs indexes", Up: backfillJobsIndexes},
+	{ID: 3, Name: "convert jobs attempts into records", Up: convertJobsAttempts},
+	{ID: 4, Name: "backfill jobs unique keys", Up: backfillJobsUniqueKeys},
+	{ID: 5, Name: "backfill jobs alternatives indexes", Up: backfillJobsAlternativesIndexes},
+	{ID: 6, Name: "backfill timetable start index", Up: backfillTimetableStartIndex},
+}
+
+// Applied is a ledger entry of an applied migration.
+type Applied struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+type Status struct {
+	Migration
+	// Applied is nil if the migration was not applied yet.
+	Applied *Applied
+}
+
+// Run applies all pending migrations. Each migration is applied in its own transaction
+// together with its ledger entry.
+func Run(db kv.Store) error {
+	for _, migration := range registry {
+		if err := db.Update(func(txn kv.Txn) error {
+			if _, err := txn.Get(ledgerKey(migration.ID)); err == nil {
+				return nil
+			} else if !errors.Is(err, kv.ErrNotFound) {
+				return err
+			}
+			if err := migration.Up(txn); err != nil {
+				return err
+			}
+			data, err := json.Marshal(Applied{
+				ID:        migration.ID,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(ledgerKey(migration.ID), data); err != nil {
+				return err
+			}
+			slog.Info("migration applied", "id", migration.ID, "name", migration.Name)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("migration %d %q: %w", migration.ID, migration.Name, err)
+		}
 	}
 	return nil
 }
 
-func renameCredentialsLoginsKey(db *badger.DB) error {
-	return db.Update(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		prefix := []byte("credentials/login")
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			newKey := bytes.TrimPrefix(it.Item().Key(), []byte("credentials/"))
-			if err := it.Item().Value(func(value []byte) error {
-				return txn.Set(newKey, value)
-			}); err != nil {
-				return fmt.Errorf("failed to set new key: %w", err)
+// errDryRun discards dry run transaction.
+var errDryRun = errors.New("dry run")
+
+// DryRun applies all pending migrations in a single transaction that is discarded afterwards.
+// Returns migrations that would be applied.
+func DryRun(db kv.Store) ([]Migration, error) {
+	statuses, err := ListStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+	pending := []Migration{}
+	if err := db.Update(func(txn kv.Txn) error {
+		for _, status := range statuses {
+			if status.Applied != nil {
+				continue
+			}
+			if err := status.Up(txn); err != nil {
+				return fmt.Errorf("migration %d %q: %w", status.ID, status.Name, err)
+			}
+			pending = append(pending, status.Migration)
+		}
+		return errDryRun
+	}); !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// ListStatuses returns all known migrations together with their ledger entries.
+func ListStatuses(db kv.Store) ([]Status, error) {
+	statuses := make([]Status, 0, len(registry))
+	if err := db.View(func(txn kv.Txn) error {
+		for _, migration := range registry {
+			status := Status{Migration: migration}
+			value, err := txn.Get(ledgerKey(migration.ID))
+			if errors.Is(err, kv.ErrNotFound) {
+				statuses = append(statuses, status)
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(value, &status.Applied); err != nil {
+				return err
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func ledgerKey(id int) []byte {
+	return []byte(fmt.Sprintf("migrations/%08d", id))
+}
+
+func renameCredentialsLoginsKey(txn kv.Txn) error {
+	return txn.Iterate([]byte("credentials/login"), func(key []byte, value []byte) error {
+		newKey := bytes.TrimPrefix(key, []byte("credentials/"))
+		if err := txn.Set(newKey, value); err != nil {
+			return fmt.Errorf("failed to set new key: %w", err)
+		}
+		if err := txn.Delete(key); err != nil {
+			return fmt.Errorf("delete old key: %w", err)
+		}
+		slog.Info(
+			"credentials migrated",
+			"old_ley", string(key),
+			"new_key", string(newKey))
+		return nil
+	})
+}
+
+// backfillJobsIndexes writes index keys for jobs inserted before indexes existed. Index keys are written as they were
+// at the time, so that the migration does not change together with the jobs store.
+func backfillJobsIndexes(txn kv.Txn) error {
+	return txn.Iterate([]byte("jobs/"), func(key []byte, value []byte) error {
+		if len(bytes.Split(key, []byte("/"))) == 3 {
+			return nil
+		}
+		var job struct {
+			ID        string `json:"id"`
+			Status    int    `json:"status"`
+			BookEvent *struct {
+				EventID       string `json:"events_id"`
+				CredentialsID string `json:"credentials_id"`
+			} `json:"book_event,omitempty"`
+		}
+		if err := json.Unmarshal(value, &job); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+		indexKeys := []string{
+			fmt.Sprintf("jobs_by_status/%d/%s", job.Status, job.ID),
+		}
+		if job.BookEvent != nil {
+			indexKeys = append(indexKeys,
+				fmt.Sprintf("jobs_by_credentials/%s/%s", job.BookEvent.CredentialsID, job.ID),
+				fmt.Sprintf("jobs_by_event/%s/%s/%s", job.BookEvent.CredentialsID, job.BookEvent.EventID, job.ID),
+			)
+		}
+		for _, indexKey := range indexKeys {
+			if err := txn.Set([]byte(indexKey), []byte(fmt.Sprintf("jobs/%s", job.ID))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// convertJobsAttempts converts attempts of jobs stored with parallel attempts and errors slices into attempt records.
+func convertJobsAttempts(txn kv.Txn) error {
+	return txn.Iterate([]byte("jobs/"), func(key []byte, value []byte) error {
+		if len(bytes.Split(key, []byte("/"))) == 3 {
+			return nil
+		}
+		var legacy struct {
+			Attempts []json.RawMessage `json:"attempts"`
+			Errors   []string          `json:"errors"`
+		}
+		if err := json.Unmarshal(value, &legacy); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+		if legacy.Errors == nil && (len(legacy.Attempts) == 0 || legacy.Attempts[0][0] != '"') {
+			// already converted
+			return nil
+		}
+		type attempt struct {
+			StartedAt time.Time `json:"started_at"`
+			Error     string    `json:"error,omitempty"`
+		}
+		attempts := make([]attempt, 0, len(legacy.Attempts))
+		for i, raw := range legacy.Attempts {
+			a := attempt{}
+			if err := json.Unmarshal(raw, &a.StartedAt); err != nil {
+				return fmt.Errorf("%q: attempts[%d]: %w", key, i, err)
 			}
-			if err := txn.Delete(it.Item().Key()); err != nil {
-				return fmt.Errorf("delete old key: %w", err)
+			if i < len(legacy.Errors) {
+				a.Error = legacy.Errors[i]
+			}
+			attempts = append(attempts, a)
+		}
+		job := map[string]json.RawMessage{}
+		if err := json.Unmarshal(value, &job); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+		data, err := json.Marshal(attempts)
+		if err != nil {
+			return err
+		}
+		job["attempts"] = data
+		delete(job, "errors")
+		data, err = json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		return txn.Set(key, data)
+	})
+}
+
+// backfillJobsUniqueKeys writes unique keys for active jobs. If there are duplicate jobs already, the first one wins.
+func backfillJobsUniqueKeys(txn kv.Txn) error {
+	return txn.Iterate([]byte("jobs/"), func(key []byte, value []byte) error {
+		if len(bytes.Split(key, []byte("/"))) == 3 {
+			return nil
+		}
+		var job struct {
+			ID        string            `json:"id"`
+			Status    int               `json:"status"`
+			Attempts  []json.RawMessage `json:"attempts"`
+			BookEvent *struct {
+				EventID       string `json:"events_id"`
+				CredentialsID string `json:"credentials_id"`
+			} `json:"book_event"`
+		}
+		if err := json.Unmarshal(value, &job); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+		if job.BookEvent == nil {
+			return nil
+		}
+		// succeeded, missed or failed without attempts left
+		if job.Status == 3 || job.Status == 5 || (job.Status == 4 && len(job.Attempts) >= 1) {
+			return nil
+		}
+		uniqueKey := []byte(fmt.Sprintf("jobs_unique/%s/%s", job.BookEvent.CredentialsID, job.BookEvent.EventID))
+		if _, err := txn.Get(uniqueKey); err == nil {
+			return nil
+		} else if !errors.Is(err, kv.ErrNotFound) {
+			return err
+		}
+		return txn.Set(uniqueKey, []byte(fmt.Sprintf("jobs/%s", job.ID)))
+	})
+}
+
+// backfillJobsAlternativesIndexes writes event index keys for alternatives of jobs inserted before alternatives were
+// indexed.
+func backfillJobsAlternativesIndexes(txn kv.Txn) error {
+	return txn.Iterate([]byte("jobs/"), func(key []byte, value []byte) error {
+		if len(bytes.Split(key, []byte("/"))) == 3 {
+			return nil
+		}
+		var job struct {
+			ID        string `json:"id"`
+			BookEvent *struct {
+				CredentialsID string   `json:"credentials_id"`
+				Alternatives  []string `json:"alternatives"`
+			} `json:"book_event,omitempty"`
+		}
+		if err := json.Unmarshal(value, &job); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+		if job.BookEvent == nil {
+			return nil
+		}
+		for _, eventID := range job.BookEvent.Alternatives {
+			indexKey := fmt.Sprintf("jobs_by_event/%s/%s/%s", job.BookEvent.CredentialsID, eventID, job.ID)
+			if err := txn.Set([]byte(indexKey), []byte(fmt.Sprintf("jobs/%s", job.ID))); err != nil {
+				return err
 			}
-			slog.Info(
-				"credentials migrated",
-				"old_ley", string(it.Item().Key()),
-				"new_key", string(newKey))
 		}
 		return nil
 	})
 }
+
+// backfillTimetableStartIndex writes start time index keys for series recorded before they were indexed.
+func backfillTimetableStartIndex(txn kv.Txn) error {
+	return txn.Iterate([]byte("timetable/"), func(key []byte, value []byte) error {
+		var series struct {
+			EventID   string    `json:"event_id"`
+			StartTime time.Time `json:"start_time"`
+		}
+		if err := json.Unmarshal(value, &series); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+		indexKey := fmt.Sprintf("timetable_by_start/%s/%s", series.StartTime.UTC().Format("2006-01-02T15:04:05Z"), series.EventID)
+		return txn.Set([]byte(indexKey), bytes.Clone(key))
+	})
+}
@@ -0,0 +1,213 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/timetable"
+)
+
+func TestRun(t *testing.T) {
+	db := kv.NewMemory()
+
+	defer func(original []Migration) { registry = original }(registry)
+
+	runs := 0
+	registry = []Migration{
+		{ID: 1, Name: "first", Up: func(txn kv.Txn) error {
+			runs++
+			return txn.Set([]byte("key"), []byte("value"))
+		}},
+	}
+
+	pending, err := DryRun(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending migration, got %d", len(pending))
+	}
+
+	if err := db.View(func(txn kv.Txn) error {
+		_, err := txn.Get([]byte("key"))
+		return err
+	}); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("expected dry run to be discarded, got %v", err)
+	}
+
+	for range 2 {
+		if err := Run(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if runs != 2 {
+		t.Fatalf("expected migration to run once after dry run, ran %d times", runs-1)
+	}
+
+	statuses, err := ListStatuses(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statuses[0].Applied == nil {
+		t.Fatal("expected migration to be applied")
+	}
+
+	if statuses[0].Applied.Name != "first" {
+		t.Fatalf("expected \"first\", got %q", statuses[0].Applied.Name)
+	}
+}
+
+func TestBackfillJobsIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := kv.NewMemory()
+	store := jobs.NewStore(db)
+
+	// jobs inserted before indexes existed
+	if err := db.Update(func(txn kv.Txn) error {
+		return txn.Set([]byte("jobs/1"), []byte(`{"id":"1","status":1,"attempts":["2024-09-01T07:00:01Z"],"errors":[""],"book_event":{"events_id":"x","credentials_id":"a"}}`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(backfillJobsIndexes); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(convertJobsAttempts); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.ListJobs(ctx, jobs.BookEventsByCredentialsIDEventIDs("a", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != "1" {
+		t.Fatalf("expected job 1, got %+v", found)
+	}
+}
+
+func TestConvertJobsAttempts(t *testing.T) {
+	ctx := context.Background()
+	db := kv.NewMemory()
+	store := jobs.NewStore(db)
+
+	if err := db.Update(func(txn kv.Txn) error {
+		return txn.Set([]byte("jobs/1"), []byte(`{"id":"1","status":4,"attempts":["2024-09-01T07:00:01Z","2024-09-01T07:00:02Z"],"errors":["full",""]}`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(convertJobsAttempts); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := store.FindByID(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(job.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(job.Attempts))
+	}
+	if job.Attempts[0].Error != "full" || job.Attempts[1].Error != "" {
+		t.Fatalf("unexpected errors: %q, %q", job.Attempts[0].Error, job.Attempts[1].Error)
+	}
+	if expected := time.Date(2024, time.September, 1, 7, 0, 2, 0, time.UTC); !job.Attempts[1].StartedAt.Equal(expected) {
+		t.Fatalf("expected %s, got %s", expected, job.Attempts[1].StartedAt)
+	}
+
+	// converting again is a no-op
+	if err := db.Update(convertJobsAttempts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackfillJobsUniqueKeys(t *testing.T) {
+	ctx := context.Background()
+	db := kv.NewMemory()
+	store := jobs.NewStore(db)
+
+	// jobs inserted before unique keys existed
+	if err := db.Update(func(txn kv.Txn) error {
+		if err := txn.Set([]byte("jobs/1"), []byte(`{"id":"1","status":1,"book_event":{"events_id":"x","credentials_id":"a"}}`)); err != nil {
+			return err
+		}
+		return txn.Set([]byte("jobs/3"), []byte(`{"id":"3","status":3,"book_event":{"events_id":"y","credentials_id":"a"}}`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(backfillJobsUniqueKeys); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, err := store.InsertUniqueJob(ctx, &jobs.Job{ID: "2", Status: jobs.StatusPending, BookEvent: &jobs.BookEventJob{CredentialsID: "a", EventID: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "1" {
+		t.Fatalf("expected existing job 1, got %s", scheduled.ID)
+	}
+
+	// finished jobs do not hold the event
+	scheduled, err = store.InsertUniqueJob(ctx, &jobs.Job{ID: "4", Status: jobs.StatusPending, BookEvent: &jobs.BookEventJob{CredentialsID: "a", EventID: "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduled.ID != "4" {
+		t.Fatalf("expected new job 4, got %s", scheduled.ID)
+	}
+}
+
+func TestBackfillJobsAlternativesIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := kv.NewMemory()
+	store := jobs.NewStore(db)
+
+	// job inserted before alternatives were indexed
+	if err := db.Update(func(txn kv.Txn) error {
+		if err := txn.Set([]byte("jobs/1"), []byte(`{"id":"1","status":1,"book_event":{"events_id":"x","credentials_id":"a","alternatives":["y"]}}`)); err != nil {
+			return err
+		}
+		return txn.Set([]byte("jobs_by_event/a/x/1"), []byte("jobs/1"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(backfillJobsAlternativesIndexes); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.ListJobs(ctx, jobs.BookEventsByCredentialsIDEventIDs("a", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != "1" {
+		t.Fatalf("expected job 1, got %+v", found)
+	}
+}
+
+func TestBackfillTimetableStartIndex(t *testing.T) {
+	ctx := context.Background()
+	db := kv.NewMemory()
+	store := timetable.NewStore(db)
+
+	// series recorded before they were indexed
+	if err := db.Update(func(txn kv.Txn) error {
+		return txn.Set([]byte("timetable/1"), []byte(`{"event_id":"1","start_time":"2025-03-03T09:00:00+01:00"}`))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(backfillTimetableStartIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	if pruned, err := store.Prune(ctx, time.Date(2025, time.March, 3, 8, 0, 1, 0, time.UTC)); err != nil || pruned != 1 {
+		t.Fatalf("expected series pruned, got %d %v", pruned, err)
+	}
+}
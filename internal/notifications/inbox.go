@@ -0,0 +1,141 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// InboxEntry is a notification as shown in the user's inbox.
+type InboxEntry struct {
+	Notification *Notification
+	// Class is the class the notification is about, nil if the notification could not be parsed.
+	Class *ParsedNotification
+	// EventID is an id of the user's booked event matching the class, empty if there is none.
+	EventID string
+	Read    bool
+}
+
+// ListInbox returns the authenticated user's notifications, newest first.
+func (s *Service) ListInbox(ctx context.Context) ([]*InboxEntry, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("token missing from context")
+	}
+	notifications, err := s.ListNotifications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	readIDs, err := s.store.ListReadIDs(ctx, token.CredentialsID)
+	if err != nil {
+		return nil, fmt.Errorf("list read ids: %w", err)
+	}
+	bookedEvents, err := s.eventsService.ListBookedEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list booked events: %w", err)
+	}
+	return inboxEntries(notifications, readIDs, bookedEvents), nil
+}
+
+// CountUnread returns a number of the authenticated user's notifications that are not read yet. It is shown on
+// every page, so only notifications listed already are counted, without calling the api. Nothing is unread until
+// they are listed.
+func (s *Service) CountUnread(ctx context.Context) (int, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return 0, fmt.Errorf("token missing from context")
+	}
+	notifications, ok := s.listedNotifications(token.CredentialsID)
+	if !ok {
+		return 0, nil
+	}
+	readIDs, err := s.store.ListReadIDs(ctx, token.CredentialsID)
+	if err != nil {
+		return 0, fmt.Errorf("list read ids: %w", err)
+	}
+	unread := 0
+	for _, notification := range notifications {
+		if !readIDs[notification.ID] {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// MarkRead marks the authenticated user's notification with the id as read. Returns ErrNotFound if the user has no
+// such notification.
+func (s *Service) MarkRead(ctx context.Context, id string) error {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return fmt.Errorf("token missing from context")
+	}
+	notifications, ok := s.listedNotifications(token.CredentialsID)
+	if !ok {
+		listed, err := s.ListNotifications(ctx)
+		if err != nil {
+			return err
+		}
+		notifications = listed
+	}
+	if !slices.ContainsFunc(notifications, func(notification *Notification) bool {
+		return notification.ID == id
+	}) {
+		return ErrNotFound
+	}
+	if err := s.store.MarkRead(ctx, token.CredentialsID, []string{id}, time.Now()); err != nil {
+		return fmt.Errorf("mark read: %w", err)
+	}
+	return nil
+}
+
+// MarkAllRead marks all of the authenticated user's notifications as read.
+func (s *Service) MarkAllRead(ctx context.Context) error {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return fmt.Errorf("token missing from context")
+	}
+	notifications, err := s.ListNotifications(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(notifications))
+	for i, notification := range notifications {
+		ids[i] = notification.ID
+	}
+	if err := s.store.MarkRead(ctx, token.CredentialsID, ids, time.Now()); err != nil {
+		return fmt.Errorf("mark read: %w", err)
+	}
+	return nil
+}
+
+func inboxEntries(notifications []*Notification, readIDs map[string]bool, bookedEvents []*events.Event) []*InboxEntry {
+	entries := make([]*InboxEntry, 0, len(notifications))
+	for _, notification := range notifications {
+		entry := &InboxEntry{
+			Notification: notification,
+			Read:         readIDs[notification.ID],
+		}
+		// notifications of unknown types are still shown, only without a class
+		if class, err := ParseNotification(notification); err == nil {
+			entry.Class = class
+			for _, event := range bookedEvents {
+				if event.DisplayName == class.DisplayName && event.StartTime.Equal(class.Time) {
+					entry.EventID = event.ID
+					break
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortStableFunc(entries, func(a, b *InboxEntry) int {
+		return b.Notification.Created.Compare(a.Notification.Created)
+	})
+	return entries
+}
@@ -0,0 +1,76 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/timezone"
+	"github.com/pilatescomplete-bot/internal/tokens"
+)
+
+func TestInboxEntries(t *testing.T) {
+	created := time.Date(2024, time.September, 1, 10, 0, 0, 0, time.UTC)
+	start := timezone.InStockholm(time.Date(2024, time.September, 2, 17, 45, 0, 0, time.UTC))
+
+	entries := inboxEntries([]*Notification{
+		{ID: "1", Type: NotificationTypeBooked, Body: "You are now booked on: Reformer Flow 2024-09-02 17:45:00 at Pilates Complete", Created: created},
+		{ID: "2", Type: NotificationTypeUnbooked, Body: "You are now unbooked from: Tower 2024-09-03 08:00:00", Created: created.Add(time.Hour)},
+		{ID: "3", Type: NotificationTypeUnknown, Body: "Welcome!", Created: created.Add(-time.Hour)},
+	}, map[string]bool{"2": true}, []*events.Event{
+		{ID: "event", DisplayName: "Reformer Flow", StartTime: start},
+	})
+
+	if len(entries) != 3 || entries[0].Notification.ID != "2" || entries[1].Notification.ID != "1" || entries[2].Notification.ID != "3" {
+		t.Fatalf("expected notifications newest first, got %+v", entries)
+	}
+	if !entries[0].Read || entries[1].Read || entries[2].Read {
+		t.Fatalf("expected only notification 2 read, got %+v", entries)
+	}
+	if entries[1].Class == nil || entries[1].Class.DisplayName != "Reformer Flow" || entries[1].EventID != "event" {
+		t.Fatalf("expected booked notification linked to the event, got %+v", entries[1])
+	}
+	if entries[0].Class == nil || entries[0].Class.DisplayName != "Tower" || entries[0].EventID != "" {
+		t.Fatalf("expected unbooked notification without event, got %+v", entries[0])
+	}
+	if entries[2].Class != nil {
+		t.Fatalf("expected unknown notification without class, got %+v", entries[2].Class)
+	}
+}
+
+func TestCountUnread(t *testing.T) {
+	ctx := tokens.NewContext(context.Background(), &tokens.Token{CredentialsID: "a"})
+	service := NewService(nil, NewStore(kv.NewMemory()), nil)
+
+	if unread, err := service.CountUnread(ctx); err != nil || unread != 0 {
+		t.Fatalf("expected nothing unread before notifications are listed, got %d %v", unread, err)
+	}
+
+	service.listed["a"] = []*Notification{{ID: "1"}, {ID: "2"}}
+	if err := service.store.MarkRead(ctx, "a", []string{"1"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if unread, err := service.CountUnread(ctx); err != nil || unread != 1 {
+		t.Fatalf("expected 1 unread, got %d %v", unread, err)
+	}
+}
+
+func TestMarkReadUnknown(t *testing.T) {
+	ctx := tokens.NewContext(context.Background(), &tokens.Token{CredentialsID: "a"})
+	service := NewService(nil, NewStore(kv.NewMemory()), nil)
+	service.listed["a"] = []*Notification{{ID: "1"}}
+
+	if err := service.MarkRead(ctx, "2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+	readIDs, err := service.store.ListReadIDs(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readIDs) != 0 {
+		t.Fatalf("expected nothing marked read, got %v", readIDs)
+	}
+}
@@ -1,6 +1,8 @@
 package notifications
 
 import (
+	"time"
+
 	"github.com/pilatescomplete-bot/internal/pilatescomplete"
 )
 
@@ -13,10 +15,24 @@ const (
 	NotificationTypeGotPlace
 )
 
+func (t NotificationType) String() string {
+	switch t {
+	case NotificationTypeBooked:
+		return "booked"
+	case NotificationTypeUnbooked:
+		return "unbooked"
+	case NotificationTypeGotPlace:
+		return "got-place"
+	default:
+		return "unknown"
+	}
+}
+
 type Notification struct {
-	ID   string
-	Type NotificationType
-	Body string
+	ID      string
+	Type    NotificationType
+	Body    string
+	Created time.Time
 }
 
 func notificationsFromAPI(notifications *pilatescomplete.ListNotificationsResponse) ([]*Notification, error) {
@@ -24,9 +40,10 @@ func notificationsFromAPI(notifications *pilatescomplete.ListNotificationsRespon
 	for i := range notifications.Notification {
 		event := notifications.Notification[i]
 		out[i] = &Notification{
-			ID:   event.Notification.ID,
-			Type: typeFromAPI(event.Notification.Type),
-			Body: event.Notification.Notification,
+			ID:      event.Notification.ID,
+			Type:    typeFromAPI(event.Notification.Type),
+			Body:    event.Notification.Notification,
+			Created: event.Notification.Created.Time(),
 		}
 	}
 	return out, nil
@@ -0,0 +1,142 @@
+package notifications
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/timezone"
+)
+
+var (
+	ErrEmptyNotification   = errors.New("empty notification")
+	ErrUnknownNotification = errors.New("notification does not match any template")
+	ErrInvalidTime         = errors.New("invalid event time")
+)
+
+// ParseError is returned when a notification body can not be parsed.
+type ParseError struct {
+	NotificationID string
+	// Header is the first line of the notification body.
+	Header string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("notification %q: %q: %s", e.NotificationID, e.Header, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// notificationTemplate describes the first line of a notification body:
+//
+//	header   = prefix name " " datetime [ location ]
+//	datetime = yyyy-mm-dd " " hh:mm [ ":" ss ]
+//	location = separator organizer
+type notificationTemplate struct {
+	Language  string
+	Type      NotificationType
+	Prefix    string
+	Separator string
+}
+
+var notificationTemplates = []notificationTemplate{
+	{Language: "sv", Type: NotificationTypeBooked, Prefix: "Du är nu bokad på:", Separator: "hos"},
+	{Language: "sv", Type: NotificationTypeUnbooked, Prefix: "Du är nu avbokad på:", Separator: "hos"},
+	{Language: "sv", Type: NotificationTypeGotPlace, Prefix: "Du har fått en plats på:", Separator: "hos"},
+	{Language: "en", Type: NotificationTypeBooked, Prefix: "You are now booked on:", Separator: "at"},
+	{Language: "en", Type: NotificationTypeUnbooked, Prefix: "You are now unbooked from:", Separator: "at"},
+	{Language: "en", Type: NotificationTypeGotPlace, Prefix: "You have got a place on:", Separator: "at"},
+}
+
+var notificationGrammars = compileGrammars(notificationTemplates)
+
+type notificationGrammar struct {
+	template notificationTemplate
+	pattern  *regexp.Regexp
+}
+
+func compileGrammars(templates []notificationTemplate) []notificationGrammar {
+	grammars := make([]notificationGrammar, len(templates))
+	for i, template := range templates {
+		grammars[i] = notificationGrammar{
+			template: template,
+			pattern: regexp.MustCompile(`^` + regexp.QuoteMeta(template.Prefix) +
+				`\s*(?P<name>.+?)\s+(?P<datetime>\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}(?::\d{2})?)` +
+				`(?:\s+` + regexp.QuoteMeta(template.Separator) + `\s+(?P<organizer>.+?))?\s*$`),
+		}
+	}
+	return grammars
+}
+
+// ParsedNotification is an event a notification is about.
+type ParsedNotification struct {
+	Language    string
+	Type        NotificationType
+	DisplayName string
+	Time        time.Time
+	// Organizer is who organizes the event, the brand rather than a studio.
+	Organizer string
+}
+
+// ParseNotification parses the first line of the notification body, using templates of its type in any language.
+func ParseNotification(notification *Notification) (*ParsedNotification, error) {
+	header := strings.TrimSpace(firstLine(notification.Body))
+	parseErr := func(err error) error {
+		return &ParseError{NotificationID: notification.ID, Header: header, Err: err}
+	}
+	if header == "" {
+		return nil, parseErr(ErrEmptyNotification)
+	}
+	for _, grammar := range notificationGrammars {
+		if grammar.template.Type != notification.Type {
+			continue
+		}
+		match := grammar.pattern.FindStringSubmatch(header)
+		if match == nil {
+			continue
+		}
+		ts, err := parseDateTime(match[grammar.pattern.SubexpIndex("datetime")])
+		if err != nil {
+			return nil, parseErr(fmt.Errorf("%w: %w", ErrInvalidTime, err))
+		}
+		return &ParsedNotification{
+			Language:    grammar.template.Language,
+			Type:        grammar.template.Type,
+			DisplayName: match[grammar.pattern.SubexpIndex("name")],
+			Time:        ts,
+			Organizer:   match[grammar.pattern.SubexpIndex("organizer")],
+		}, nil
+	}
+	return nil, parseErr(ErrUnknownNotification)
+}
+
+func parseDateTime(value string) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), " ")
+	layout := time.DateTime
+	if len(value) == len("2006-01-02 15:04") {
+		layout = "2006-01-02 15:04"
+	}
+	ts, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return timezone.InStockholm(ts), nil
+}
+
+func firstLine(str string) string {
+	r := strings.NewReader(str)
+	scanner := bufio.NewScanner(r)
+	if scanner.Scan() {
+		return scanner.Text()
+	}
+	if err := scanner.Err(); err != nil {
+		return ""
+	}
+	return ""
+}
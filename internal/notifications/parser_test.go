@@ -0,0 +1,76 @@
+package notifications
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/timezone"
+)
+
+func TestParseNotification(t *testing.T) {
+	at := func(value string) time.Time {
+		ts, err := time.Parse(time.DateTime, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return timezone.InStockholm(ts)
+	}
+
+	for fixture, tc := range map[string]struct {
+		typ      NotificationType
+		expected *ParsedNotification
+		err      error
+	}{
+		"sv_booked.txt":     {NotificationTypeBooked, &ParsedNotification{Language: "sv", DisplayName: "Reformer Flow", Time: at("2024-09-01 11:15:00"), Organizer: "Pilates Complete"}, nil},
+		"sv_unbooked.txt":   {NotificationTypeUnbooked, &ParsedNotification{Language: "sv", DisplayName: "Reformer Flow", Time: at("2024-09-01 11:15:00"), Organizer: "Pilates Complete"}, nil},
+		"sv_got_place.txt":  {NotificationTypeGotPlace, &ParsedNotification{Language: "sv", DisplayName: "Mat Pilates 45", Time: at("2024-10-03 07:00:00"), Organizer: "Pilates Complete"}, nil},
+		"en_booked.txt":     {NotificationTypeBooked, &ParsedNotification{Language: "en", DisplayName: "Reformer Flow", Time: at("2024-09-02 17:45:00"), Organizer: "Pilates Complete"}, nil},
+		"en_unbooked.txt":   {NotificationTypeUnbooked, &ParsedNotification{Language: "en", DisplayName: "Reformer Flow", Time: at("2024-09-02 17:45:00"), Organizer: "Pilates Complete"}, nil},
+		"en_got_place.txt":  {NotificationTypeGotPlace, &ParsedNotification{Language: "en", DisplayName: "Tower", Time: at("2024-09-05 08:00:00"), Organizer: "Pilates Complete"}, nil},
+		"invalid_short.txt": {NotificationTypeBooked, nil, ErrUnknownNotification},
+		"invalid_time.txt":  {NotificationTypeBooked, nil, ErrInvalidTime},
+		"invalid_empty.txt": {NotificationTypeBooked, nil, ErrEmptyNotification},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "notifications", fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseNotification(&Notification{ID: "id", Type: tc.typ, Body: string(body)})
+			if tc.err != nil {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) || parseErr.NotificationID != "id" {
+					t.Fatalf("expected parse error, got %v", err)
+				}
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.expected.Type = tc.typ
+			if !parsed.Time.Equal(tc.expected.Time) {
+				t.Fatalf("expected time %s, got %s", tc.expected.Time, parsed.Time)
+			}
+			parsed.Time = tc.expected.Time
+			if *parsed != *tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestParseNotificationWrongType(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "notifications", "sv_booked.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseNotification(&Notification{Type: NotificationTypeUnbooked, Body: string(body)}); !errors.Is(err, ErrUnknownNotification) {
+		t.Fatalf("expected %v, got %v", ErrUnknownNotification, err)
+	}
+}
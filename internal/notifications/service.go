@@ -3,19 +3,34 @@ package notifications
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sync"
 
+	"github.com/pilatescomplete-bot/internal/events"
 	"github.com/pilatescomplete-bot/internal/pilatescomplete"
+	"github.com/pilatescomplete-bot/internal/tokens"
 )
 
 type Service struct {
-	apiClient *pilatescomplete.APIClient
+	apiClient     *pilatescomplete.APIClient
+	store         *Store
+	eventsService *events.Service
+
+	listedGuard sync.RWMutex
+	// listed are notifications by credentials id, as last listed from the api
+	listed map[string][]*Notification
 }
 
 func NewService(
 	apiClient *pilatescomplete.APIClient,
+	store *Store,
+	eventsService *events.Service,
 ) *Service {
 	return &Service{
-		apiClient: apiClient,
+		apiClient:     apiClient,
+		store:         store,
+		eventsService: eventsService,
+		listed:        make(map[string][]*Notification),
 	}
 }
 
@@ -23,6 +38,26 @@ func (s *Service) ListNotifications(ctx context.Context) ([]*Notification, error
 	return s.listNotiications(ctx, pilatescomplete.ListNotificationsInput{})
 }
 
+// FilterNotificationsAfter returns notifications created after the one with the id. The api has no cursor, so it is
+// not incremental: all notifications are listed and the ones up to the id are dropped. If there is no such
+// notification, all notifications are returned.
+func (s *Service) FilterNotificationsAfter(ctx context.Context, id string) ([]*Notification, error) {
+	notifications, err := s.listNotiications(ctx, pilatescomplete.ListNotificationsInput{})
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return notifications, nil
+	}
+	for i, notification := range notifications {
+		if notification.ID == id {
+			return notifications[i+1:], nil
+		}
+	}
+	slog.WarnContext(ctx, "notification not found, returning all notifications", "notification_id", id, "notifications", len(notifications))
+	return notifications, nil
+}
+
 func (s *Service) listNotiications(ctx context.Context, input pilatescomplete.ListNotificationsInput) ([]*Notification, error) {
 	apiResponse, err := s.apiClient.ListNotifications(ctx, input)
 	if err != nil {
@@ -32,5 +67,19 @@ func (s *Service) listNotiications(ctx context.Context, input pilatescomplete.Li
 	if err != nil {
 		return nil, fmt.Errorf("events from api: %w", err)
 	}
+	if token, ok := tokens.FromContext(ctx); ok {
+		s.listedGuard.Lock()
+		s.listed[token.CredentialsID] = events
+		s.listedGuard.Unlock()
+	}
 	return events, nil
 }
+
+// listedNotifications returns notifications of the credentials id as last listed from the api, by the statistics
+// sync in background or by the inbox. The second value is false if they were not listed yet.
+func (s *Service) listedNotifications(credentialsID string) ([]*Notification, bool) {
+	s.listedGuard.RLock()
+	defer s.listedGuard.RUnlock()
+	notifications, ok := s.listed[credentialsID]
+	return notifications, ok
+}
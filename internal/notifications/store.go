@@ -0,0 +1,61 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+// Store keeps track of notifications read by each user. Notifications themselves are not stored, they are listed
+// from the api.
+type Store struct {
+	db kv.Store
+}
+
+func NewStore(db kv.Store) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// MarkRead marks notifications with the ids as read at the time.
+func (s *Store) MarkRead(_ context.Context, credentialsID string, ids []string, now time.Time) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		data, err := json.Marshal(now)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := txn.Set(readKey(credentialsID, id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListReadIDs returns a set of ids of notifications read by the credentials id.
+func (s *Store) ListReadIDs(_ context.Context, credentialsID string) (map[string]bool, error) {
+	ids := map[string]bool{}
+	if err := s.db.View(func(txn kv.Txn) error {
+		prefix := readPrefix(credentialsID)
+		return txn.Iterate(prefix, func(key []byte, _ []byte) error {
+			ids[string(key[len(prefix):])] = true
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func readPrefix(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("notifications/%s/read/", credentialsID))
+}
+
+func readKey(credentialsID string, id string) []byte {
+	return append(readPrefix(credentialsID), id...)
+}
@@ -0,0 +1,37 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	if err := store.MarkRead(ctx, "a", []string{"1", "2"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkRead(ctx, "b", []string{"3"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := store.ListReadIDs(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || !ids["1"] || !ids["2"] {
+		t.Fatalf("expected notifications 1 and 2 read, got %v", ids)
+	}
+
+	ids, err = store.ListReadIDs(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("expected no notifications read, got %v", ids)
+	}
+}
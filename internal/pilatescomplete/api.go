@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/pilatescomplete-bot/internal/tokens"
 )
@@ -29,11 +30,70 @@ var (
 
 type APIClient struct {
 	httpClient http.Client
+	clock      *Clock
 }
 
 func NewAPIClient() *APIClient {
 	return &APIClient{
 		httpClient: http.Client{},
+		clock:      &Clock{},
+	}
+}
+
+// Clock returns the server clock estimated from responses.
+func (c APIClient) Clock() *Clock {
+	return c.clock
+}
+
+// do sends the request, sampling the server clock from the response.
+func (c APIClient) do(req *http.Request) (*http.Response, error) {
+	sent := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	c.clock.Observe(resp.Header, sent, time.Now())
+	return resp, nil
+}
+
+// Probe samples the server clock with a lightweight request.
+func (c APIClient) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://pilatescomplete.wondr.se/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// WatchClock probes the server clock every interval until context is cancelled, and warns when the
+// skew exceeds the threshold.
+func (c APIClient) WatchClock(ctx context.Context, interval time.Duration, threshold time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	exceeded := false
+	for {
+		if err := c.Probe(ctx); err != nil {
+			slog.ErrorContext(ctx, "probe clock", "error", err)
+		} else {
+			skew := c.clock.Skew()
+			if skew.Offset.Abs() > threshold && !exceeded {
+				slog.WarnContext(ctx, "clock skew exceeds threshold", "offset", skew.Offset, "threshold", threshold)
+			} else if skew.Offset.Abs() <= threshold && exceeded {
+				slog.InfoContext(ctx, "clock skew is within threshold", "offset", skew.Offset, "threshold", threshold)
+			}
+			exceeded = skew.Offset.Abs() > threshold
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -124,7 +184,7 @@ func (c APIClient) ListEvents(ctx context.Context, input ListEventsInput) (*List
 	}
 	slog.InfoContext(ctx, "api request", "method", req.Method, "url", req.URL)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -159,23 +219,36 @@ var (
 	ErrOverbooked              = errors.New("you are currently booked on maximum allowed simultaneous bookings")
 )
 
+// errorsByCode maps API error codes to known errors.
+var errorsByCode = map[string]error{
+	"USER_ALREADY_BOOKED":               ErrActivityAlreadyBooked,
+	"ACTIVITY_BOOKING_TO_EARLY":         ErrActivityBookingTooEarly,
+	"ACCESS_NOT_ALLOWED":                ErrAccessNotAllowed,
+	"USER_OVER_MAX_CONCURRENT_BOOKINGS": ErrOverbooked,
+}
+
 func (r APIResponse) Error() error {
 	if r.Result != "error" {
 		return nil
 	}
-	if r.ErrorCode == "USER_ALREADY_BOOKED" {
-		return ErrActivityAlreadyBooked
-	}
-	if r.ErrorCode == "ACTIVITY_BOOKING_TO_EARLY" {
-		return ErrActivityBookingTooEarly
+	if err, ok := errorsByCode[r.ErrorCode]; ok {
+		return err
 	}
-	if r.ErrorCode == "ACCESS_NOT_ALLOWED" {
-		return ErrAccessNotAllowed
+	return r.ErrorResponse
+}
+
+// ErrorCode returns API error code of the error, or an empty string if the error did not come from the API.
+func ErrorCode(err error) string {
+	var errorResponse ErrorResponse
+	if errors.As(err, &errorResponse) {
+		return errorResponse.ErrorCode
 	}
-	if r.ErrorCode == "USER_OVER_MAX_CONCURRENT_BOOKINGS" {
-		return ErrOverbooked
+	for code, knownErr := range errorsByCode {
+		if errors.Is(err, knownErr) {
+			return code
+		}
 	}
-	return r.ErrorResponse
+	return ""
 }
 
 func (r APIResponse) IsOK() bool {
@@ -208,7 +281,7 @@ func (c APIClient) BookActivity(ctx context.Context, activityID string) (*Activi
 	}
 	slog.InfoContext(ctx, "api request", "method", req.Method, "url", req.URL)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -247,7 +320,7 @@ func (c APIClient) CancelBooking(ctx context.Context, activityBookingID string)
 	}
 	slog.InfoContext(ctx, "api request", "method", req.Method, "url", req.URL)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -308,7 +381,7 @@ func (c APIClient) ListNotifications(ctx context.Context, input ListNotification
 	}
 	slog.InfoContext(ctx, "api request", "method", req.Method, "url", req.URL)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -0,0 +1,99 @@
+package pilatescomplete
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockMaxSamples is how many latest samples are used for the offset estimate, older samples are dropped so that the
+// estimate follows the server clock when it drifts.
+const clockMaxSamples = 16
+
+// Clock estimates offset between the local clock and the server clock from Date headers of responses.
+//
+// Date has a second precision, so a single sample only tells that the offset is within a range about a second wide.
+// Ranges of the latest samples are intersected, which narrows the range as samples come in. Now uses the lower end of
+// the range, so that jobs never fire before they are due on the server.
+type Clock struct {
+	mu         sync.RWMutex
+	ranges     []offsetRange
+	samples    int
+	lastSample time.Time
+}
+
+// offsetRange is a range the offset is known to be within.
+type offsetRange struct {
+	min time.Duration
+	max time.Duration
+}
+
+// ClockSkew is a snapshot of the clock estimate.
+type ClockSkew struct {
+	// Offset is how much the server clock is ahead of the local clock, the middle of the range it's known to be within.
+	Offset time.Duration
+	// MinOffset is how much the server clock is at least ahead of the local clock.
+	MinOffset  time.Duration
+	Samples    int
+	LastSample time.Time
+}
+
+// Observe adds a sample from a response with the Date header, for a request sent and received at the given times.
+func (c *Clock) Observe(header http.Header, sent time.Time, received time.Time) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// the server time was truncated to date at some point between sent and received
+	sample := offsetRange{
+		min: date.Sub(received),
+		max: date.Add(time.Second).Sub(sent),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ranges = append(c.ranges, sample)
+	if len(c.ranges) > clockMaxSamples {
+		c.ranges = c.ranges[len(c.ranges)-clockMaxSamples:]
+	}
+	c.samples++
+	c.lastSample = received
+}
+
+// offset returns the intersection of the latest ranges. If the server clock was adjusted, older ranges no longer
+// intersect with newer ones, so only the ranges since the adjustment are used.
+func (c *Clock) offset() offsetRange {
+	if len(c.ranges) == 0 {
+		return offsetRange{}
+	}
+	offset := c.ranges[len(c.ranges)-1]
+	for i := len(c.ranges) - 2; i >= 0; i-- {
+		next := offset
+		next.min = max(next.min, c.ranges[i].min)
+		next.max = min(next.max, c.ranges[i].max)
+		if next.min > next.max {
+			break
+		}
+		offset = next
+	}
+	return offset
+}
+
+func (c *Clock) Skew() ClockSkew {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	offset := c.offset()
+	return ClockSkew{
+		Offset:     offset.min + (offset.max-offset.min)/2,
+		MinOffset:  offset.min,
+		Samples:    c.samples,
+		LastSample: c.lastSample,
+	}
+}
+
+// Now returns the earliest time it can be on the server now.
+func (c *Clock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Now().Add(c.offset().min)
+}
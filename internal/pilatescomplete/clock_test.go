@@ -0,0 +1,41 @@
+package pilatescomplete
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	clock := &Clock{}
+	sent := time.Date(2024, time.September, 1, 7, 0, 0, 0, time.UTC)
+
+	header := http.Header{}
+	// server is about 10 seconds ahead
+	header.Set("Date", sent.Add(10*time.Second).Format(http.TimeFormat))
+	clock.Observe(header, sent, sent.Add(time.Second))
+	if skew := clock.Skew(); skew.Offset != 10*time.Second || skew.MinOffset != 9*time.Second || skew.Samples != 1 {
+		t.Fatalf("unexpected skew after first sample: %+v", skew)
+	}
+
+	// a sample taken at another fraction of a second narrows the range
+	sent = sent.Add(10*time.Second + 500*time.Millisecond)
+	header.Set("Date", sent.Add(10*time.Second).Format(http.TimeFormat))
+	clock.Observe(header, sent, sent.Add(100*time.Millisecond))
+	if skew := clock.Skew(); skew.MinOffset != 9400*time.Millisecond || skew.Offset != 9950*time.Millisecond || skew.Samples != 2 {
+		t.Fatalf("unexpected skew after second sample: %+v", skew)
+	}
+
+	// server clock was adjusted, older samples are no longer used
+	header.Set("Date", sent.Format(http.TimeFormat))
+	clock.Observe(header, sent, sent.Add(100*time.Millisecond))
+	if skew := clock.Skew(); skew.MinOffset != -600*time.Millisecond || skew.Offset != -50*time.Millisecond || skew.Samples != 3 {
+		t.Fatalf("unexpected skew after adjustment: %+v", skew)
+	}
+
+	// responses without date are ignored
+	clock.Observe(http.Header{}, sent, sent)
+	if skew := clock.Skew(); skew.Samples != 3 {
+		t.Fatalf("expected response without date to be ignored: %+v", skew)
+	}
+}
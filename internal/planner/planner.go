@@ -0,0 +1,212 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/authentication"
+	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/jobs"
+	"github.com/pilatescomplete-bot/internal/pilatescomplete"
+	"github.com/pilatescomplete-bot/internal/settings"
+)
+
+// Planner keeps booking jobs of users who are at their membership limits queued, and releases them in priority
+// order once an earlier class is finished or cancelled.
+type Planner struct {
+	jobsStore             *jobs.Store
+	scheduler             *jobs.Scheduler
+	eventsService         *events.Service
+	settingsStore         *settings.Store
+	authenticationService *authentication.Service
+
+	interval time.Duration
+}
+
+func NewPlanner(
+	jobsStore *jobs.Store,
+	scheduler *jobs.Scheduler,
+	eventsService *events.Service,
+	settingsStore *settings.Store,
+	authenticationService *authentication.Service,
+	interval time.Duration,
+) *Planner {
+	return &Planner{
+		jobsStore:             jobsStore,
+		scheduler:             scheduler,
+		eventsService:         eventsService,
+		settingsStore:         settingsStore,
+		authenticationService: authenticationService,
+		interval:              interval,
+	}
+}
+
+// Run releases queued jobs every interval, until context is cancelled.
+func (p *Planner) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "stopping planner")
+			return nil
+		case <-ticker.C:
+			if err := p.ReleaseAll(ctx); err != nil {
+				slog.ErrorContext(ctx, "release queued jobs", "error", err)
+			}
+		}
+	}
+}
+
+// ReleaseAll releases queued jobs of all users.
+func (p *Planner) ReleaseAll(ctx context.Context) error {
+	queued, err := p.jobsStore.ListJobs(ctx, jobs.ByStatus(jobs.StatusQueued))
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	credentialsIDs := map[string]bool{}
+	for _, job := range queued {
+		if job.BookEvent != nil {
+			credentialsIDs[job.BookEvent.CredentialsID] = true
+		}
+	}
+	for credentialsID := range credentialsIDs {
+		if _, err := p.Release(ctx, credentialsID); err != nil {
+			slog.ErrorContext(ctx, "release queued jobs", "credentials_id", credentialsID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Release releases queued jobs of the credentials id in priority order, as long as user's limits allow.
+// Jobs of events that have started are marked as missed. Nothing is released until a limit is learned, because
+// released jobs would fail and be queued again on every run. Returns number of released jobs.
+func (p *Planner) Release(ctx context.Context, credentialsID string) (int, error) {
+	queue, err := p.scheduler.ListQueuedJobs(ctx, credentialsID)
+	if err != nil {
+		return 0, fmt.Errorf("list queued jobs: %w", err)
+	}
+	if len(queue) == 0 {
+		return 0, nil
+	}
+	settings, err := p.settingsStore.Get(ctx, credentialsID)
+	if err != nil {
+		return 0, fmt.Errorf("get settings: %w", err)
+	}
+	limitsKnown := settings.MaxBookings > 0 || settings.MaxDailyBookings > 0
+	usage := &Usage{}
+	if limitsKnown {
+		usage, err = p.usage(ctx, credentialsID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	released := 0
+	for _, job := range queue {
+		if job.BookEvent == nil {
+			continue
+		}
+		if job.BookEvent.EventStartTime.Before(now) {
+			if err := p.scheduler.MarkMissed(ctx, job); err != nil {
+				return released, fmt.Errorf("mark missed: %w", err)
+			}
+			continue
+		}
+		if !limitsKnown {
+			continue
+		}
+		if settings.MaxBookings > 0 && usage.Active >= settings.MaxBookings {
+			// following jobs must wait for their turn
+			break
+		}
+		day := dayKey(job.BookEvent.EventStartTime)
+		if settings.MaxDailyBookings > 0 && usage.Daily[day] >= settings.MaxDailyBookings {
+			continue
+		}
+		if err := p.scheduler.Release(ctx, job); err != nil {
+			return released, fmt.Errorf("release: %w", err)
+		}
+		slog.InfoContext(ctx, "released queued job", "job_id", job.ID)
+		usage.Active++
+		usage.Daily[day]++
+		released++
+	}
+	return released, nil
+}
+
+// Learn updates limits of the credentials id from an error booking an event starting at eventStartTime failed with.
+// When user is over a limit, the limit equals to the number of bookings they have.
+func (p *Planner) Learn(ctx context.Context, credentialsID string, eventStartTime time.Time, errorCode string) error {
+	overbooked := errorCode == pilatescomplete.ErrorCode(pilatescomplete.ErrOverbooked)
+	dailyLimit := errorCode == pilatescomplete.ErrorCode(pilatescomplete.ErrAccessNotAllowed)
+	if !overbooked && !dailyLimit {
+		return nil
+	}
+	settings, err := p.settingsStore.Get(ctx, credentialsID)
+	if err != nil {
+		return fmt.Errorf("get settings: %w", err)
+	}
+	usage, err := p.usage(ctx, credentialsID)
+	if err != nil {
+		return err
+	}
+	if overbooked && usage.Active > 0 {
+		settings.MaxBookings = usage.Active
+	}
+	if count := usage.Daily[dayKey(eventStartTime)]; dailyLimit && count > 0 {
+		settings.MaxDailyBookings = count
+	}
+	if err := p.settingsStore.Insert(ctx, settings); err != nil {
+		return fmt.Errorf("insert settings: %w", err)
+	}
+	slog.InfoContext(ctx, "learned booking limits",
+		"credentials_id", credentialsID,
+		"max_bookings", settings.MaxBookings,
+		"max_daily_bookings", settings.MaxDailyBookings,
+	)
+	return nil
+}
+
+func (p *Planner) usage(ctx context.Context, credentialsID string) (*Usage, error) {
+	ctx, err := p.authenticationService.AuthenticateContext(ctx, credentialsID)
+	if err != nil {
+		return nil, fmt.Errorf("authenticate context: %w", err)
+	}
+	booked, err := p.eventsService.ListBookedEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list booked events: %w", err)
+	}
+	return newUsage(booked, time.Now()), nil
+}
+
+// Usage is how many bookings count towards user's limits.
+type Usage struct {
+	// Active is a number of booked or reserved events that have not finished yet.
+	Active int
+	// Daily is a number of booked or reserved events by day.
+	Daily map[string]int
+}
+
+func newUsage(booked []*events.Event, now time.Time) *Usage {
+	usage := &Usage{
+		Daily: map[string]int{},
+	}
+	for _, event := range booked {
+		if event.Booking == nil || !(event.Booking.IsBooked() || event.Booking.IsReserved()) {
+			continue
+		}
+		usage.Daily[dayKey(event.StartTime)]++
+		if event.EndTime.After(now) {
+			usage.Active++
+		}
+	}
+	return usage
+}
+
+func dayKey(ts time.Time) string {
+	return ts.Format(time.DateOnly)
+}
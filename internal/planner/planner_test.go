@@ -0,0 +1,38 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/bookings"
+	"github.com/pilatescomplete-bot/internal/events"
+)
+
+func TestUsage(t *testing.T) {
+	now := time.Date(2024, time.September, 2, 12, 0, 0, 0, time.UTC)
+	event := func(start time.Time, status bookings.BookingStatus) *events.Event {
+		return &events.Event{
+			StartTime: start,
+			EndTime:   start.Add(55 * time.Minute),
+			Booking:   &bookings.Booking{Status: status},
+		}
+	}
+
+	usage := newUsage([]*events.Event{
+		event(now.Add(-3*time.Hour), bookings.BookingStatusChecked),
+		event(now.Add(-2*time.Hour), bookings.BookingStatusBooked),
+		event(now.Add(-30*time.Minute), bookings.BookingStatusBooked),
+		event(now.Add(24*time.Hour), bookings.BookingStatusReserved),
+		event(now.Add(48*time.Hour), bookings.BookingStatusJobScheduled),
+	}, now)
+
+	if usage.Active != 2 {
+		t.Errorf("expected 2 active bookings, got %d", usage.Active)
+	}
+	if count := usage.Daily[dayKey(now)]; count != 2 {
+		t.Errorf("expected 2 bookings today, got %d", count)
+	}
+	if count := usage.Daily[dayKey(now.Add(24*time.Hour))]; count != 1 {
+		t.Errorf("expected 1 booking tomorrow, got %d", count)
+	}
+}
@@ -0,0 +1,25 @@
+package settings
+
+import "time"
+
+// DefaultMinLocationGap is used until user changes it.
+const DefaultMinLocationGap = 30 * time.Minute
+
+type Settings struct {
+	CredentialsID string `json:"credentials_id"`
+	// MinLocationGap is a minimum time between classes at different locations, zero disables the check.
+	MinLocationGap time.Duration `json:"min_location_gap"`
+	// MaxBookings is a maximum number of simultaneous bookings allowed by user's membership, zero if unknown.
+	MaxBookings int `json:"max_bookings"`
+	// MaxDailyBookings is a maximum number of bookings on the same day allowed by user's membership, zero if unknown.
+	MaxDailyBookings int `json:"max_daily_bookings"`
+	// ReserveFirstChoice keeps a reservation of a full event, instead of booking its alternatives.
+	ReserveFirstChoice bool `json:"reserve_first_choice"`
+}
+
+func Default(credentialsID string) *Settings {
+	return &Settings{
+		CredentialsID:  credentialsID,
+		MinLocationGap: DefaultMinLocationGap,
+	}
+}
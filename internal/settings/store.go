@@ -0,0 +1,52 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+type Store struct {
+	db kv.Store
+}
+
+func NewStore(db kv.Store) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Insert(_ context.Context, settings *Settings) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return err
+		}
+		return txn.Set(credentialsIDKey(settings.CredentialsID), data)
+	})
+}
+
+// Get returns settings of the credentials id, or defaults if there are none stored.
+func (s *Store) Get(_ context.Context, credentialsID string) (*Settings, error) {
+	settings := Default(credentialsID)
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(credentialsIDKey(credentialsID))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(value, settings)
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return settings, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+func credentialsIDKey(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("settings/%s", credentialsID))
+}
@@ -0,0 +1,34 @@
+package settings
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	settings, err := store.Get(ctx, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *settings != *Default("id") {
+		t.Fatalf("expected defaults, got %+v", settings)
+	}
+
+	settings.MinLocationGap = time.Hour
+	if err := store.Insert(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	found, err := store.Get(ctx, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.MinLocationGap != time.Hour {
+		t.Fatalf("expected %s, got %s", time.Hour, found.MinLocationGap)
+	}
+}
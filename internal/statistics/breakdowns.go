@@ -0,0 +1,104 @@
+package statistics
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Count is a number of attended classes with the same name.
+type Count struct {
+	Name  string
+	Total int
+}
+
+// Breakdowns are attended classes grouped by details of events.
+type Breakdowns struct {
+	// Trainers and Locations are ordered from the most attended. Classes without known details are not counted.
+	Trainers  []Count
+	Locations []Count
+	Heatmap   Heatmap
+}
+
+// Heatmap counts attended classes by weekday and hour of the start.
+type Heatmap struct {
+	// Hours are hours of the day with at least one class, in order.
+	Hours []int
+	// Rows are weekdays from Monday to Sunday.
+	Rows []HeatmapRow
+	// Max is the largest count of a single cell.
+	Max int
+}
+
+type HeatmapRow struct {
+	Weekday time.Weekday
+	// Cells are counts for every hour in Heatmap.Hours.
+	Cells []int
+}
+
+type breakdownsBuilder struct {
+	trainers  map[string]int
+	locations map[string]int
+	// cells are counts by weekday, starting at Monday, and hour
+	cells [7][24]int
+}
+
+func newBreakdownsBuilder() *breakdownsBuilder {
+	return &breakdownsBuilder{
+		trainers:  map[string]int{},
+		locations: map[string]int{},
+	}
+}
+
+func (b *breakdownsBuilder) add(entry *entry) {
+	if entry.TrainerName != "" {
+		b.trainers[entry.TrainerName]++
+	}
+	if entry.LocationDisplayName != "" {
+		b.locations[entry.LocationDisplayName]++
+	}
+	b.cells[(int(entry.Time.Weekday())+6)%7][entry.Time.Hour()]++
+}
+
+func (b *breakdownsBuilder) build() Breakdowns {
+	breakdowns := Breakdowns{
+		Trainers:  sortedCounts(b.trainers),
+		Locations: sortedCounts(b.locations),
+	}
+	for hour := 0; hour < 24; hour++ {
+		for day := 0; day < 7; day++ {
+			if b.cells[day][hour] > 0 {
+				breakdowns.Heatmap.Hours = append(breakdowns.Heatmap.Hours, hour)
+				break
+			}
+		}
+	}
+	for day := 0; day < 7; day++ {
+		row := HeatmapRow{
+			Weekday: time.Weekday((day + 1) % 7),
+		}
+		for _, hour := range breakdowns.Heatmap.Hours {
+			row.Cells = append(row.Cells, b.cells[day][hour])
+			breakdowns.Heatmap.Max = max(breakdowns.Heatmap.Max, b.cells[day][hour])
+		}
+		breakdowns.Heatmap.Rows = append(breakdowns.Heatmap.Rows, row)
+	}
+	return breakdowns
+}
+
+func sortedCounts(totals map[string]int) []Count {
+	counts := make([]Count, 0, len(totals))
+	for name, total := range totals {
+		counts = append(counts, Count{
+			Name:  name,
+			Total: total,
+		})
+	}
+	slices.SortFunc(counts, func(a, b Count) int {
+		if a.Total != b.Total {
+			return b.Total - a.Total
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return counts
+}
@@ -0,0 +1,54 @@
+package statistics
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBreakdowns(t *testing.T) {
+	builder := newBreakdownsBuilder()
+	class := func(trainer, location string, start time.Time) *entry {
+		return &entry{Time: start, TrainerName: trainer, LocationDisplayName: location}
+	}
+	monday := time.Date(2024, time.September, 2, 17, 45, 0, 0, time.UTC)
+	for _, entry := range []*entry{
+		class("Anna", "Odenplan", monday),
+		class("Anna", "Odenplan", monday.AddDate(0, 0, 7)),
+		class("Bea", "Odenplan", monday.AddDate(0, 0, 6).Add(-10*time.Hour)),
+		class("Cecilia", "Hornstull", monday.AddDate(0, 0, 2)),
+		// notification only, details are not known
+		class("", "", monday.AddDate(0, 0, 2)),
+	} {
+		builder.add(entry)
+	}
+	breakdowns := builder.build()
+
+	if expected := []Count{{"Anna", 2}, {"Bea", 1}, {"Cecilia", 1}}; !slices.Equal(breakdowns.Trainers, expected) {
+		t.Fatalf("expected trainers %v, got %v", expected, breakdowns.Trainers)
+	}
+	if expected := []Count{{"Odenplan", 3}, {"Hornstull", 1}}; !slices.Equal(breakdowns.Locations, expected) {
+		t.Fatalf("expected locations %v, got %v", expected, breakdowns.Locations)
+	}
+
+	heatmap := breakdowns.Heatmap
+	if expected := []int{7, 17}; !slices.Equal(heatmap.Hours, expected) {
+		t.Fatalf("expected hours %v, got %v", expected, heatmap.Hours)
+	}
+	if heatmap.Max != 2 {
+		t.Fatalf("expected max 2, got %d", heatmap.Max)
+	}
+	expected := []HeatmapRow{
+		{Weekday: time.Monday, Cells: []int{0, 2}},
+		{Weekday: time.Tuesday, Cells: []int{0, 0}},
+		{Weekday: time.Wednesday, Cells: []int{0, 2}},
+		{Weekday: time.Thursday, Cells: []int{0, 0}},
+		{Weekday: time.Friday, Cells: []int{0, 0}},
+		{Weekday: time.Saturday, Cells: []int{0, 0}},
+		{Weekday: time.Sunday, Cells: []int{1, 0}},
+	}
+	if !reflect.DeepEqual(heatmap.Rows, expected) {
+		t.Fatalf("expected rows %v, got %v", expected, heatmap.Rows)
+	}
+}
@@ -0,0 +1,153 @@
+package statistics
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatJSON ExportFormat = "json"
+	ExportFormatICS  ExportFormat = "ics"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseExportFormat returns the export format of a file extension, without the dot.
+func ParseExportFormat(extension string) (ExportFormat, error) {
+	switch format := ExportFormat(extension); format {
+	case ExportFormatCSV, ExportFormatJSON, ExportFormatICS:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%q: %w", extension, ErrUnknownFormat)
+	}
+}
+
+// ContentType returns a media type of the exported file.
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatCSV:
+		return "text/csv"
+	case ExportFormatJSON:
+		return "application/json"
+	case ExportFormatICS:
+		return "text/calendar"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// defaultClassDuration is used as a duration of classes whose end is not known.
+const defaultClassDuration = time.Hour
+
+// ExportedClass is an attended class in an export.
+type ExportedClass struct {
+	DisplayName         string      `json:"display_name"`
+	StartTime           time.Time   `json:"start_time"`
+	EndTime             time.Time   `json:"end_time"`
+	TrainerName         string      `json:"trainer_name,omitempty"`
+	LocationDisplayName string      `json:"location_display_name,omitempty"`
+	Status              EntryStatus `json:"status"`
+	BookedAt            *time.Time  `json:"booked_at,omitempty"`
+}
+
+// Export writes past classes of the authenticated user, that were neither unbooked nor missed, starting in
+// [from, to) in the format.
+func (s *Service) Export(ctx context.Context, w io.Writer, format ExportFormat, from, to time.Time) error {
+	entries, err := s.listLedger(ctx)
+	if err != nil {
+		return fmt.Errorf("list ledger: %w", err)
+	}
+	classes := exportedClasses(entries, from, to, time.Now())
+	switch format {
+	case ExportFormatCSV:
+		return writeCSV(w, classes)
+	case ExportFormatJSON:
+		return json.NewEncoder(w).Encode(classes)
+	case ExportFormatICS:
+		return writeICS(w, classes)
+	default:
+		return fmt.Errorf("%q: %w", format, ErrUnknownFormat)
+	}
+}
+
+func exportedClasses(entries []*entry, from, to time.Time, now time.Time) []*ExportedClass {
+	classes := []*ExportedClass{}
+	for _, entry := range entries {
+		if entry.Status == EntryStatusUnbooked || entry.Status == EntryStatusMissed {
+			continue
+		}
+		if entry.Time.After(now) || entry.Time.Before(from) || !entry.Time.Before(to) {
+			continue
+		}
+		class := &ExportedClass{
+			DisplayName:         entry.DisplayName,
+			StartTime:           entry.Time,
+			EndTime:             entry.EndTime,
+			TrainerName:         entry.TrainerName,
+			LocationDisplayName: entry.LocationDisplayName,
+			Status:              entry.Status,
+		}
+		if class.EndTime.IsZero() {
+			class.EndTime = entry.Time.Add(defaultClassDuration)
+		}
+		if !entry.BookedAt.IsZero() {
+			class.BookedAt = &entry.BookedAt
+		}
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+func writeCSV(w io.Writer, classes []*ExportedClass) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"display_name", "start_time", "end_time", "trainer_name", "location_display_name", "status", "booked_at"}); err != nil {
+		return err
+	}
+	for _, class := range classes {
+		bookedAt := ""
+		if class.BookedAt != nil {
+			bookedAt = class.BookedAt.Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{
+			class.DisplayName,
+			class.StartTime.Format(time.RFC3339),
+			class.EndTime.Format(time.RFC3339),
+			class.TrainerName,
+			class.LocationDisplayName,
+			string(class.Status),
+			bookedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeICS(w io.Writer, classes []*ExportedClass) error {
+	icalendar := ics.NewCalendar()
+	icalendar.SetName("Pilates complete history")
+	for _, class := range classes {
+		ievent := icalendar.AddEvent(fmt.Sprintf("%s-%s", class.StartTime.UTC().Format("20060102T150405Z"), class.DisplayName))
+		ievent.SetSummary(class.DisplayName)
+		if class.TrainerName != "" {
+			ievent.SetDescription(class.TrainerName)
+		}
+		if class.LocationDisplayName != "" {
+			ievent.SetLocation(class.LocationDisplayName)
+		}
+		ievent.SetStartAt(class.StartTime)
+		ievent.SetEndAt(class.EndTime)
+	}
+	return icalendar.SerializeTo(w)
+}
@@ -0,0 +1,68 @@
+package statistics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportedClasses(t *testing.T) {
+	now := time.Date(2025, time.March, 6, 12, 0, 0, 0, time.UTC)
+	from := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+	class := func(name string, status EntryStatus, start time.Time) *entry {
+		return &entry{DisplayName: name, Status: status, Time: start}
+	}
+	withDetails := class("Reformer", EntryStatusChecked, from.AddDate(0, 1, 0))
+	withDetails.TrainerName = "Anna"
+	withDetails.LocationDisplayName = "Odenplan"
+	withDetails.EndTime = withDetails.Time.Add(50 * time.Minute)
+
+	classes := exportedClasses([]*entry{
+		class("Before", EntryStatusChecked, from.Add(-time.Minute)),
+		class("First", EntryStatusBooked, from),
+		class("Unbooked", EntryStatusUnbooked, from.AddDate(0, 0, 1)),
+		class("Missed", EntryStatusMissed, from.AddDate(0, 0, 2)),
+		withDetails,
+		class("Future", EntryStatusBooked, now.Add(time.Hour)),
+	}, from, to, now)
+
+	var names []string
+	for _, class := range classes {
+		names = append(names, class.DisplayName)
+	}
+	if got := strings.Join(names, ","); got != "First,Reformer" {
+		t.Fatalf("expected First,Reformer, got %s", got)
+	}
+	if got := classes[0].EndTime.Sub(classes[0].StartTime); got != defaultClassDuration {
+		t.Fatalf("expected default duration, got %s", got)
+	}
+
+	out := &bytes.Buffer{}
+	if err := writeCSV(out, classes); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header and 2 records, got %d", len(records))
+	}
+	if expected := "Reformer,2025-02-01T00:00:00Z,2025-02-01T00:50:00Z,Anna,Odenplan,checked,"; strings.Join(records[2], ",") != expected {
+		t.Fatalf("expected %q, got %q", expected, strings.Join(records[2], ","))
+	}
+
+	out.Reset()
+	if err := writeICS(out, classes); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), "BEGIN:VEVENT"); got != 2 {
+		t.Fatalf("expected 2 events, got %d", got)
+	}
+	if !strings.Contains(out.String(), "LOCATION:Odenplan") {
+		t.Fatalf("expected location in %s", out.String())
+	}
+}
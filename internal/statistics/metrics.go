@@ -0,0 +1,141 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// LateCancellationWindow is how long before the start an unbooking counts as a late cancellation.
+const LateCancellationWindow = 12 * time.Hour
+
+// Metrics describe attendance quality over a year.
+type Metrics struct {
+	// Booked is a number of past classes that were booked, including unbooked ones.
+	Booked int `json:"booked"`
+	// Checked is a number of classes checked in to.
+	Checked int `json:"checked"`
+	// NoShows is a number of booked classes that were missed.
+	NoShows int `json:"no_shows"`
+	// Unbooked is a number of classes that were unbooked.
+	Unbooked int `json:"unbooked"`
+	// LateCancellations is a number of classes unbooked within LateCancellationWindow before the start.
+	LateCancellations int `json:"late_cancellations"`
+	// AttendanceRate is a share of checked classes among classes with a known outcome, in percent.
+	AttendanceRate float64 `json:"attendance_rate"`
+	// CancellationRate is a share of unbooked classes among booked classes, in percent.
+	CancellationRate float64 `json:"cancellation_rate"`
+	// CurrentStreak is a number of consecutive weeks with attended classes up to now. The current week only
+	// extends the streak, it does not break it.
+	CurrentStreak int `json:"current_streak"`
+	// LongestStreak is the largest number of consecutive weeks with attended classes.
+	LongestStreak int `json:"longest_streak"`
+	// AveragePerWeek is an average number of attended classes per week.
+	AveragePerWeek float64 `json:"average_per_week"`
+}
+
+// HasOutcomes returns true if any class with a known outcome is counted.
+func (m *Metrics) HasOutcomes() bool {
+	return m.Checked+m.NoShows > 0
+}
+
+func (s *Service) CalculateMetrics(ctx context.Context, year int) (*Metrics, error) {
+	entries, err := s.listLedger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list ledger: %w", err)
+	}
+	return calculateMetrics(entries, year, time.Now()), nil
+}
+
+func calculateMetrics(entries []*entry, year int, now time.Time) *Metrics {
+	metrics := &Metrics{}
+	attendedWeeks := map[time.Time]bool{}
+	attended := 0
+	for _, entry := range entries {
+		if entry.Time.After(now) {
+			continue
+		}
+		if entry.Status != EntryStatusUnbooked && entry.Status != EntryStatusMissed {
+			attendedWeeks[weekStart(entry.Time)] = true
+		}
+		if entry.Time.Year() != year {
+			continue
+		}
+		metrics.Booked++
+		switch entry.Status {
+		case EntryStatusChecked:
+			metrics.Checked++
+			attended++
+		case EntryStatusMissed:
+			metrics.NoShows++
+		case EntryStatusUnbooked:
+			metrics.Unbooked++
+			if !entry.UnbookedAt.IsZero() && entry.Time.Sub(entry.UnbookedAt) < LateCancellationWindow {
+				metrics.LateCancellations++
+			}
+		default:
+			// booked classes without an outcome are assumed to be attended
+			attended++
+		}
+	}
+	if metrics.HasOutcomes() {
+		metrics.AttendanceRate = percent(metrics.Checked, metrics.Checked+metrics.NoShows)
+	}
+	if metrics.Booked > 0 {
+		metrics.CancellationRate = percent(metrics.Unbooked, metrics.Booked)
+	}
+	metrics.CurrentStreak, metrics.LongestStreak = streaks(attendedWeeks, year, now)
+	if weeks := weeksElapsed(year, now); weeks > 0 {
+		metrics.AveragePerWeek = math.Round(float64(attended)/weeks*10) / 10
+	}
+	return metrics
+}
+
+// streaks returns the current streak ending this or the previous week, and the longest streak of weeks in the
+// year.
+func streaks(attendedWeeks map[time.Time]bool, year int, now time.Time) (int, int) {
+	current := 0
+	week := weekStart(now)
+	if !attendedWeeks[week] {
+		week = week.AddDate(0, 0, -7)
+	}
+	for ; attendedWeeks[week]; week = week.AddDate(0, 0, -7) {
+		current++
+	}
+
+	longest, streak := 0, 0
+	yearEnd := getDateFromISOWeek(year+1, 1)
+	for week := getDateFromISOWeek(year, 1); week.Before(yearEnd) && !week.After(now); week = week.AddDate(0, 0, 7) {
+		if !attendedWeeks[week] {
+			streak = 0
+			continue
+		}
+		streak++
+		longest = max(longest, streak)
+	}
+	return current, longest
+}
+
+// weekStart returns the date of Monday of the week of the time, as UTC midnight like getDateFromISOWeek.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// weeksElapsed returns a number of weeks of the year that have passed by now.
+func weeksElapsed(year int, now time.Time) float64 {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, now.Location())
+	end := start.AddDate(1, 0, 0)
+	if now.Before(end) {
+		end = now
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return math.Max(end.Sub(start).Hours()/24/7, 1)
+}
+
+func percent(part, total int) float64 {
+	return math.Round(float64(part)/float64(total)*1000) / 10
+}
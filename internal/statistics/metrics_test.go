@@ -0,0 +1,59 @@
+package statistics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalculateMetrics(t *testing.T) {
+	// Thursday of week 10
+	now := time.Date(2025, time.March, 6, 12, 0, 0, 0, time.UTC)
+	class := func(status EntryStatus, start time.Time) *entry {
+		return &entry{Time: start, DisplayName: "Reformer", Status: status}
+	}
+	week := func(week int, day int) time.Time {
+		return getDateFromISOWeek(2025, week).AddDate(0, 0, day).Add(18 * time.Hour)
+	}
+	unbooked := func(start time.Time, before time.Duration) *entry {
+		entry := class(EntryStatusUnbooked, start)
+		entry.UnbookedAt = start.Add(-before)
+		return entry
+	}
+
+	metrics := calculateMetrics([]*entry{
+		// previous year extends the streak into the year, but is not counted otherwise
+		class(EntryStatusChecked, time.Date(2024, time.December, 30, 18, 0, 0, 0, time.UTC)),
+		class(EntryStatusChecked, week(2, 0)),
+		class(EntryStatusChecked, week(3, 1)),
+		class(EntryStatusMissed, week(4, 0)),
+		class(EntryStatusChecked, week(7, 0)),
+		class(EntryStatusChecked, week(8, 0)),
+		// booked without outcome yet is attended
+		class(EntryStatusBooked, week(9, 2)),
+		unbooked(week(9, 3), 2*time.Hour),
+		unbooked(week(9, 4), 48*time.Hour),
+		// future
+		class(EntryStatusBooked, week(10, 5)),
+	}, 2025, now)
+
+	expected := &Metrics{
+		Booked:            8,
+		Checked:           4,
+		NoShows:           1,
+		Unbooked:          2,
+		LateCancellations: 1,
+		AttendanceRate:    80,
+		CancellationRate:  25,
+		// week 10 has nothing attended yet, so the streak is weeks 7-9
+		CurrentStreak:  3,
+		LongestStreak:  3,
+		AveragePerWeek: 0.5,
+	}
+	if *metrics != *expected {
+		t.Fatalf("expected %+v, got %+v", expected, metrics)
+	}
+
+	if metrics := calculateMetrics(nil, 2026, now); metrics.HasOutcomes() || metrics.AveragePerWeek != 0 {
+		t.Fatalf("expected empty metrics for a future year, got %+v", metrics)
+	}
+}
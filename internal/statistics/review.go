@@ -0,0 +1,94 @@
+package statistics
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// reviewTopSize is a number of top classes and trainers in a review.
+const reviewTopSize = 3
+
+// Review is a summary of a year.
+type Review struct {
+	Year  int
+	Total int
+	// TopClasses and TopTrainers are the most attended, at most reviewTopSize of each.
+	TopClasses  []Class
+	TopTrainers []Count
+	// BusiestMonth is the month with most attended classes, zero Number if there were none.
+	BusiestMonth  Month
+	LongestStreak int
+	// FirstClass and LastClass are nil if there were no classes.
+	FirstClass *ReviewClass
+	LastClass  *ReviewClass
+}
+
+type ReviewClass struct {
+	DisplayName string
+	Time        time.Time
+}
+
+func (s *Service) CalculateReview(ctx context.Context, year int) (*Review, error) {
+	entries, err := s.calculateEnteries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("calculate entries: %w", err)
+	}
+	return calculateReview(entries, year, time.Now()), nil
+}
+
+func calculateReview(entries []*entry, year int, now time.Time) *Review {
+	review := &Review{
+		Year: year,
+	}
+	classesByName := map[string]int{}
+	trainers := map[string]int{}
+	months := [12]int{}
+	attendedWeeks := map[time.Time]bool{}
+	for _, entry := range entries {
+		if entry.Status == EntryStatusUnbooked || entry.Status == EntryStatusMissed || entry.Time.After(now) {
+			continue
+		}
+		attendedWeeks[weekStart(entry.Time)] = true
+		if entry.Time.Year() != year {
+			continue
+		}
+		review.Total++
+		classesByName[entry.DisplayName]++
+		if entry.TrainerName != "" {
+			trainers[entry.TrainerName]++
+		}
+		months[entry.Time.Month()-1]++
+		class := &ReviewClass{
+			DisplayName: entry.DisplayName,
+			Time:        entry.Time,
+		}
+		if review.FirstClass == nil || entry.Time.Before(review.FirstClass.Time) {
+			review.FirstClass = class
+		}
+		if review.LastClass == nil || entry.Time.After(review.LastClass.Time) {
+			review.LastClass = class
+		}
+	}
+
+	for _, count := range sortedCounts(classesByName) {
+		review.TopClasses = append(review.TopClasses, Class{
+			DisplayName: count.Name,
+			Total:       count.Total,
+		})
+	}
+	review.TopClasses = review.TopClasses[:min(len(review.TopClasses), reviewTopSize)]
+	review.TopTrainers = sortedCounts(trainers)
+	review.TopTrainers = review.TopTrainers[:min(len(review.TopTrainers), reviewTopSize)]
+
+	for i, total := range months {
+		if total > review.BusiestMonth.Total {
+			review.BusiestMonth = Month{
+				Number: i + 1,
+				Total:  total,
+			}
+		}
+	}
+	_, review.LongestStreak = streaks(attendedWeeks, year, now)
+	return review
+}
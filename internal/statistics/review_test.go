@@ -0,0 +1,48 @@
+package statistics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCalculateReview(t *testing.T) {
+	now := time.Date(2025, time.December, 31, 12, 0, 0, 0, time.UTC)
+	class := func(name string, trainer string, status EntryStatus, start time.Time) *entry {
+		return &entry{DisplayName: name, TrainerName: trainer, Status: status, Time: start}
+	}
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2025, month, day, 18, 0, 0, 0, time.UTC)
+	}
+
+	review := calculateReview([]*entry{
+		class("Reformer", "Anna", EntryStatusChecked, time.Date(2024, time.December, 20, 18, 0, 0, 0, time.UTC)),
+		class("Reformer", "Anna", EntryStatusChecked, day(time.January, 8)),
+		class("Tower", "", EntryStatusBooked, day(time.March, 3)),
+		class("Reformer", "Bea", EntryStatusChecked, day(time.March, 10)),
+		class("Barre", "Anna", EntryStatusChecked, day(time.March, 17)),
+		class("Mat", "Cecilia", EntryStatusChecked, day(time.March, 24)),
+		class("Mat", "Dana", EntryStatusChecked, day(time.November, 24)),
+		// missed and unbooked classes are not reviewed
+		class("Tower", "Bea", EntryStatusMissed, day(time.March, 31)),
+		class("Tower", "Bea", EntryStatusUnbooked, day(time.December, 1)),
+	}, 2025, now)
+
+	expected := &Review{
+		Year:          2025,
+		Total:         6,
+		TopClasses:    []Class{{"Mat", 2}, {"Reformer", 2}, {"Barre", 1}},
+		TopTrainers:   []Count{{"Anna", 2}, {"Bea", 1}, {"Cecilia", 1}},
+		BusiestMonth:  Month{Total: 4, Number: 3},
+		LongestStreak: 4,
+		FirstClass:    &ReviewClass{DisplayName: "Reformer", Time: day(time.January, 8)},
+		LastClass:     &ReviewClass{DisplayName: "Mat", Time: day(time.November, 24)},
+	}
+	if !reflect.DeepEqual(review, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, review)
+	}
+
+	if review := calculateReview(nil, 2025, now); review.FirstClass != nil || review.BusiestMonth.Number != 0 {
+		t.Fatalf("expected empty review, got %+v", review)
+	}
+}
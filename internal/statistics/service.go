@@ -1,26 +1,51 @@
 package statistics
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"maps"
+	"log/slog"
 	"slices"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/pilatescomplete-bot/internal/authentication"
+	"github.com/pilatescomplete-bot/internal/credentials"
+	"github.com/pilatescomplete-bot/internal/events"
 	"github.com/pilatescomplete-bot/internal/notifications"
+	"github.com/pilatescomplete-bot/internal/tokens"
 )
 
 type Service struct {
-	notificationsSerice *notifications.Service
+	notificationsSerice   *notifications.Service
+	store                 *Store
+	authenticationService *authentication.Service
+	credentialsStore      *credentials.Store
+	eventsService         *events.Service
+
+	syncGuard sync.Mutex
+	// syncAttempts is when ledgers were last synced by credentials id, successfully or not
+	syncAttempts map[string]time.Time
 }
 
+// minSyncInterval is how often a ledger is synced at most, so that page loads and the background sync don't
+// hammer wondr.se, also when syncs fail.
+const minSyncInterval = time.Minute
+
 func NewService(
 	notificationsService *notifications.Service,
+	store *Store,
+	authenticationService *authentication.Service,
+	credentialsStore *credentials.Store,
+	eventsService *events.Service,
 ) *Service {
 	return &Service{
-		notificationsSerice: notificationsService,
+		notificationsSerice:   notificationsService,
+		store:                 store,
+		authenticationService: authenticationService,
+		credentialsStore:      credentialsStore,
+		eventsService:         eventsService,
+		syncAttempts:          map[string]time.Time{},
 	}
 }
 
@@ -43,6 +68,7 @@ func (s *Service) CalculateYearWeek(ctx context.Context, year int, week int) (*W
 	}
 
 	classesByName := map[string]int{}
+	breakdowns := newBreakdownsBuilder()
 	now := time.Now()
 	for _, entry := range entries {
 		if entry.Time.After(now) {
@@ -58,7 +84,9 @@ func (s *Service) CalculateYearWeek(ctx context.Context, year int, week int) (*W
 		stats.Total++
 		stats.Days[daysIndex[entry.Time.Day()]].Total++
 		classesByName[entry.DisplayName]++
+		breakdowns.add(entry)
 	}
+	stats.Breakdowns = breakdowns.build()
 
 	for displayName, total := range classesByName {
 		stats.Classes = append(stats.Classes, Class{
@@ -91,6 +119,7 @@ func (s *Service) CalculateYearMonth(ctx context.Context, year int, month time.M
 		}
 	}
 	classesByName := map[string]int{}
+	breakdowns := newBreakdownsBuilder()
 	now := time.Now()
 	for _, entry := range entries {
 		if entry.Time.After(now) {
@@ -106,7 +135,9 @@ func (s *Service) CalculateYearMonth(ctx context.Context, year int, month time.M
 		stats.Total++
 		stats.Weeks[weekIndex[week]].Total++
 		classesByName[entry.DisplayName]++
+		breakdowns.add(entry)
 	}
+	stats.Breakdowns = breakdowns.build()
 
 	for displayName, total := range classesByName {
 		stats.Classes = append(stats.Classes, Class{
@@ -139,6 +170,7 @@ func (s *Service) CalculateYear(ctx context.Context, year int) (*YearStatistics,
 		}
 	}
 	classesByName := map[string]int{}
+	breakdowns := newBreakdownsBuilder()
 	now := time.Now()
 	for _, entry := range entries {
 		if entry.Time.After(now) {
@@ -150,7 +182,9 @@ func (s *Service) CalculateYear(ctx context.Context, year int) (*YearStatistics,
 		stats.Total++
 		stats.Months[monthIndex[int(entry.Time.Month())]].Total++
 		classesByName[entry.DisplayName]++
+		breakdowns.add(entry)
 	}
+	stats.Breakdowns = breakdowns.build()
 	for displayName, total := range classesByName {
 		stats.Classes = append(stats.Classes, Class{
 			Total:       total,
@@ -163,83 +197,228 @@ func (s *Service) CalculateYear(ctx context.Context, year int) (*YearStatistics,
 	return stats, nil
 }
 
+// CountAttended returns a number of past classes starting in [from, to) that were neither unbooked nor missed.
+func (s *Service) CountAttended(ctx context.Context, from, to time.Time) (int, error) {
+	entries, err := s.calculateEnteries(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("calculate entries: %w", err)
+	}
+	count := 0
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.Time.After(now) {
+			continue
+		}
+		if entry.Time.Before(from) || !entry.Time.Before(to) {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+type EntryStatus string
+
+const (
+	EntryStatusBooked   EntryStatus = "booked"
+	EntryStatusUnbooked EntryStatus = "unbooked"
+	EntryStatusChecked  EntryStatus = "checked"
+	EntryStatusMissed   EntryStatus = "missed"
+)
+
 type entry struct {
-	Time        time.Time
-	DisplayName string
+	// NotificationID is an id of the last notification the entry is parsed from.
+	NotificationID string `json:"notification_id"`
+	// EventID is known from booked events only, notifications don't contain it.
+	EventID     string      `json:"event_id,omitempty"`
+	Time        time.Time   `json:"time"`
+	DisplayName string      `json:"display_name"`
+	Status      EntryStatus `json:"status"`
+	// TrainerName, LocationDisplayName and EndTime are known from booked events only, notifications don't
+	// contain them.
+	TrainerName         string    `json:"trainer_name,omitempty"`
+	LocationDisplayName string    `json:"location_display_name,omitempty"`
+	EndTime             time.Time `json:"end_time,omitempty"`
+	// BookedAt is a time of the booking notification, zero if the entry is known from an outcome only.
+	BookedAt time.Time `json:"booked_at,omitempty"`
+	// UnbookedAt is a time of the unbooking notification.
+	UnbookedAt time.Time `json:"unbooked_at,omitempty"`
 }
 
-func (s *Service) calculateEnteries(ctx context.Context) ([]*entry, error) {
-	nn, err := s.notificationsSerice.ListNotifications(ctx)
+// IsOutcome returns true if it's known whether the event was attended.
+func (e *entry) IsOutcome() bool {
+	return e.Status == EntryStatusChecked || e.Status == EntryStatusMissed
+}
+
+// Run syncs ledgers of all credentials every interval, until context is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.syncAll(ctx)
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "stopping statistics sync")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) syncAll(ctx context.Context) {
+	credentialsIDs, err := s.credentialsStore.ListIDs(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("list notifications: %w", err)
+		slog.ErrorContext(ctx, "list credentials", "error", err)
+		return
 	}
-	entriesByDate := make(map[time.Time]*entry, len(nn))
-	for _, notification := range nn {
-		switch notification.Type {
-		case notifications.NotificationTypeBooked:
-			entry, err := parseBookedNotification(notification.Body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse booked notification: %w", err)
-			}
-			entriesByDate[entry.Time] = entry
-		case notifications.NotificationTypeGotPlace:
-			entry, err := parseGotPlaceNotification(notification.Body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse got place notification: %w", err)
-			}
-			entriesByDate[entry.Time] = entry
-		case notifications.NotificationTypeUnbooked:
-			entry, err := parseUnbookedNotification(notification.Body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse unbooked notification: %w", err)
-			}
-			delete(entriesByDate, entry.Time)
-		default:
-			continue
+	for _, credentialsID := range credentialsIDs {
+		if _, err := s.Sync(ctx, credentialsID); err != nil {
+			slog.ErrorContext(ctx, "sync statistics", "credentials_id", credentialsID, "error", err)
 		}
 	}
-	return slices.Collect(maps.Values(entriesByDate)), nil
 }
 
-func firstLine(str string) string {
-	r := strings.NewReader(str)
-	scanner := bufio.NewScanner(r)
-	if scanner.Scan() {
-		return scanner.Text()
+// Sync ingests notifications of the credentials id created since the last sync into the ledger, followed by
+// outcomes and details of booked events. Returns number of ingested notifications. The ledger is not synced if it
+// was tried less than minSyncInterval ago.
+func (s *Service) Sync(ctx context.Context, credentialsID string) (int, error) {
+	ctx, err := s.authenticationService.AuthenticateContext(ctx, credentialsID)
+	if err != nil {
+		return 0, fmt.Errorf("authenticate context: %w", err)
 	}
-	if err := scanner.Err(); err != nil {
-		return ""
+	return s.sync(ctx, credentialsID)
+}
+
+func (s *Service) sync(ctx context.Context, credentialsID string) (int, error) {
+	s.syncGuard.Lock()
+	if time.Since(s.syncAttempts[credentialsID]) < minSyncInterval {
+		s.syncGuard.Unlock()
+		return 0, nil
 	}
-	return ""
+	s.syncAttempts[credentialsID] = time.Now()
+	s.syncGuard.Unlock()
+
+	cursor, err := s.store.Cursor(ctx, credentialsID)
+	if err != nil && !errors.Is(err, ErrNoCursor) {
+		return 0, fmt.Errorf("cursor: %w", err)
+	}
+	nn, err := s.notificationsSerice.FilterNotificationsAfter(ctx, cursor)
+	if err != nil {
+		return 0, fmt.Errorf("list notifications: %w", err)
+	}
+	if len(nn) > 0 {
+		if err := s.store.Apply(ctx, credentialsID, changesFromNotifications(ctx, nn), nn[len(nn)-1].ID); err != nil {
+			return 0, fmt.Errorf("apply: %w", err)
+		}
+	}
+	booked, err := s.eventsService.ListBookedEvents(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list booked events: %w", err)
+	}
+	bookedChanges := changesFromBookedEvents(booked)
+	if len(bookedChanges) > 0 {
+		if err := s.store.Apply(ctx, credentialsID, bookedChanges, ""); err != nil {
+			return 0, fmt.Errorf("apply booked events: %w", err)
+		}
+	}
+	// marked also when there were no notifications, so that the ledger is not synced on every page load
+	if err := s.store.MarkSynced(ctx, credentialsID, time.Now()); err != nil {
+		return 0, fmt.Errorf("mark synced: %w", err)
+	}
+	slog.InfoContext(ctx, "synced statistics", "credentials_id", credentialsID, "notifications", len(nn), "booked_events", len(bookedChanges))
+	return len(nn), nil
 }
 
-func parseNotification(notification string, prefix string) (*entry, error) {
-	prefixLength := len(prefix)
-	const suffixLength = len(" hos Pilates Complete")
-	const dateTimeLength = len("2024-09-01 11:15:00")
-	header := firstLine(notification)
-	value := header[prefixLength : len(header)-suffixLength]
-	time, err := time.Parse(time.DateTime, value[len(value)-dateTimeLength:])
+// calculateEnteries returns entries of classes that were neither unbooked nor missed from the ledger of the
+// authenticated user.
+func (s *Service) calculateEnteries(ctx context.Context) ([]*entry, error) {
+	entries, err := s.listLedger(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse date: %w", err)
+		return nil, err
 	}
-	displayName := value[:len(value)-dateTimeLength-1]
-	return &entry{
-		DisplayName: displayName,
-		Time:        time,
-	}, nil
+	return slices.DeleteFunc(entries, func(entry *entry) bool {
+		return entry.Status == EntryStatusUnbooked || entry.Status == EntryStatusMissed
+	}), nil
 }
 
-func parseUnbookedNotification(notification string) (*entry, error) {
-	return parseNotification(notification, "Du är nu avbokad på: ")
+// listLedger returns all entries from the ledger of the authenticated user. The ledger is synced first if it
+// never was, otherwise it's kept up to date in background.
+func (s *Service) listLedger(ctx context.Context) ([]*entry, error) {
+	token, ok := tokens.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("token missing from context")
+	}
+	if _, err := s.store.SyncedAt(ctx, token.CredentialsID); errors.Is(err, ErrNeverSynced) {
+		if _, err := s.sync(ctx, token.CredentialsID); err != nil {
+			return nil, fmt.Errorf("sync: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("synced at: %w", err)
+	}
+	return s.store.ListEntries(ctx, token.CredentialsID)
 }
 
-func parseGotPlaceNotification(notification string) (*entry, error) {
-	return parseNotification(notification, "Du har fått en plats på: ")
+// changesFromNotifications returns changes to the ledger, in order of notifications. Notifications that can not
+// be parsed are skipped.
+func changesFromNotifications(ctx context.Context, nn []*notifications.Notification) []change {
+	changes := make([]change, 0, len(nn))
+	for _, notification := range nn {
+		if notification.Type == notifications.NotificationTypeUnknown {
+			continue
+		}
+		parsed, err := notifications.ParseNotification(notification)
+		if err != nil {
+			slog.InfoContext(ctx, "skipping notification", "error", err)
+			continue
+		}
+		entry := &entry{
+			NotificationID: notification.ID,
+			Time:           parsed.Time,
+			DisplayName:    parsed.DisplayName,
+			Status:         EntryStatusBooked,
+			BookedAt:       notification.Created,
+		}
+		if notification.Type == notifications.NotificationTypeUnbooked {
+			entry.Status = EntryStatusUnbooked
+			entry.BookedAt = time.Time{}
+			entry.UnbookedAt = notification.Created
+		}
+		changes = append(changes, change{Entry: entry})
+	}
+	return changes
 }
 
-func parseBookedNotification(notification string) (*entry, error) {
-	return parseNotification(notification, "Du är nu bokad på: ")
+// changesFromBookedEvents returns changes to the ledger with outcomes and details of booked events.
+func changesFromBookedEvents(ee []*events.Event) []change {
+	var changes []change
+	for _, event := range ee {
+		if event.Booking == nil {
+			continue
+		}
+		var status EntryStatus
+		switch {
+		case event.Booking.IsBooked():
+			status = EntryStatusBooked
+		case event.Booking.IsChecked():
+			status = EntryStatusChecked
+		case event.Booking.IsMissed():
+			status = EntryStatusMissed
+		default:
+			continue
+		}
+		changes = append(changes, change{
+			Entry: &entry{
+				EventID:             event.ID,
+				Time:                event.StartTime,
+				DisplayName:         event.DisplayName,
+				Status:              status,
+				TrainerName:         event.TrainerName,
+				LocationDisplayName: event.LocationDisplayName,
+				EndTime:             event.EndTime,
+			},
+		})
+	}
+	return changes
 }
 
 // getDateFromISOWeek returns the date of Monday for the given ISO week
@@ -11,15 +11,17 @@ type Month struct {
 }
 
 type YearStatistics struct {
-	Total   int
-	Months  []Month
-	Classes []Class
+	Total      int
+	Months     []Month
+	Classes    []Class
+	Breakdowns Breakdowns
 }
 
 type MonthStatistics struct {
-	Total   int
-	Weeks   []Week
-	Classes []Class
+	Total      int
+	Weeks      []Week
+	Classes    []Class
+	Breakdowns Breakdowns
 }
 
 type Week struct {
@@ -34,7 +36,8 @@ type Day struct {
 }
 
 type WeekStatistics struct {
-	Total   int
-	Days    []Day
-	Classes []Class
+	Total      int
+	Days       []Day
+	Classes    []Class
+	Breakdowns Breakdowns
 }
@@ -0,0 +1,227 @@
+package statistics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+// Store is an attendance ledger, keeping booked events parsed from notifications per credentials id.
+type Store struct {
+	db kv.Store
+}
+
+func NewStore(db kv.Store) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// change is a booked or unbooked event parsed from a notification, or an outcome and details of a booked event.
+type change struct {
+	Entry *entry
+}
+
+// Apply applies changes to the ledger of the credentials id in order, and moves the cursor to the notification id.
+// An empty cursor leaves the cursor as it is.
+func (s *Store) Apply(_ context.Context, credentialsID string, changes []change, cursor string) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		for _, change := range changes {
+			if err := applyChange(txn, credentialsID, change); err != nil {
+				return err
+			}
+		}
+		if cursor == "" {
+			return nil
+		}
+		return txn.Set(cursorKey(credentialsID), []byte(cursor))
+	})
+}
+
+// applyChange merges the change into the entry of its event. Notifications don't have event ids, so they are
+// matched to entries by class. An entry known from notifications only is keyed by the id of its first notification,
+// and is moved under the event id once the event is known.
+func applyChange(txn kv.Txn, credentialsID string, change change) error {
+	key := entryKey(credentialsID, change.Entry)
+	classKey := entryClassKey(credentialsID, change.Entry)
+	existingKey, err := txn.Get(classKey)
+	if errors.Is(err, kv.ErrNotFound) {
+		existingKey = nil
+	} else if err != nil {
+		return err
+	}
+	if change.Entry.EventID == "" && existingKey != nil {
+		key = existingKey
+	}
+
+	var existing *entry
+	for _, k := range [][]byte{key, existingKey} {
+		if k == nil {
+			continue
+		}
+		value, err := txn.Get(k)
+		if errors.Is(err, kv.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		existing = &entry{}
+		if err := json.Unmarshal(value, existing); err != nil {
+			return err
+		}
+		// an entry of another event of the same class is not merged
+		if change.Entry.EventID != "" && existing.EventID != "" && existing.EventID != change.Entry.EventID {
+			existing = nil
+			continue
+		}
+		if !bytes.Equal(k, key) {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		break
+	}
+
+	data, err := json.Marshal(mergeEntry(existing, change))
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(key, data); err != nil {
+		return err
+	}
+	return txn.Set(classKey, key)
+}
+
+// mergeEntry returns the existing entry with the change applied. Outcomes are never overwritten by booking
+// notifications, since an event is booked before it is attended.
+func mergeEntry(existing *entry, change change) *entry {
+	merged := *change.Entry
+	if existing == nil {
+		return &merged
+	}
+	if merged.EventID == "" {
+		merged.EventID = existing.EventID
+	}
+	if merged.NotificationID == "" {
+		merged.NotificationID = existing.NotificationID
+	}
+	if merged.BookedAt.IsZero() {
+		merged.BookedAt = existing.BookedAt
+	}
+	if merged.TrainerName == "" {
+		merged.TrainerName = existing.TrainerName
+	}
+	if merged.LocationDisplayName == "" {
+		merged.LocationDisplayName = existing.LocationDisplayName
+	}
+	if merged.EndTime.IsZero() {
+		merged.EndTime = existing.EndTime
+	}
+	if merged.Status == EntryStatusBooked && existing.IsOutcome() {
+		merged.Status = existing.Status
+	}
+	return &merged
+}
+
+// ErrNoCursor is returned when the ledger was never synced.
+var ErrNoCursor = errors.New("no cursor")
+
+// Cursor returns id of the last notification ingested into the ledger of the credentials id.
+func (s *Store) Cursor(_ context.Context, credentialsID string) (string, error) {
+	var cursor string
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(cursorKey(credentialsID))
+		if err != nil {
+			return err
+		}
+		cursor = string(value)
+		return nil
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return "", ErrNoCursor
+		}
+		return "", err
+	}
+	return cursor, nil
+}
+
+// ErrNeverSynced is returned when the ledger was never synced.
+var ErrNeverSynced = errors.New("never synced")
+
+// MarkSynced records when the ledger of the credentials id was synced, also if there was nothing to ingest.
+func (s *Store) MarkSynced(_ context.Context, credentialsID string, at time.Time) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		return txn.Set(syncedAtKey(credentialsID), []byte(at.UTC().Format(time.RFC3339Nano)))
+	})
+}
+
+// SyncedAt returns when the ledger of the credentials id was last synced.
+func (s *Store) SyncedAt(_ context.Context, credentialsID string) (time.Time, error) {
+	var syncedAt time.Time
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(syncedAtKey(credentialsID))
+		if err != nil {
+			return err
+		}
+		syncedAt, err = time.Parse(time.RFC3339Nano, string(value))
+		return err
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return time.Time{}, ErrNeverSynced
+		}
+		return time.Time{}, err
+	}
+	return syncedAt, nil
+}
+
+// ListEntries returns entries in the ledger of the credentials id, ordered by time.
+func (s *Store) ListEntries(_ context.Context, credentialsID string) ([]*entry, error) {
+	var entries []*entry
+	if err := s.db.View(func(txn kv.Txn) error {
+		return txn.Iterate(entriesPrefix(credentialsID), func(_ []byte, value []byte) error {
+			entry := &entry{}
+			if err := json.Unmarshal(value, entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(entries, func(a, b *entry) int {
+		return a.Time.Compare(b.Time)
+	})
+	return entries, nil
+}
+
+func cursorKey(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("statistics/%s/cursor", credentialsID))
+}
+
+func syncedAtKey(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("statistics/%s/synced_at", credentialsID))
+}
+
+func entriesPrefix(credentialsID string) []byte {
+	return []byte(fmt.Sprintf("statistics/%s/entries/", credentialsID))
+}
+
+// entryKey is keyed by the event id, or by the notification id if the event is not known yet.
+func entryKey(credentialsID string, entry *entry) []byte {
+	if entry.EventID != "" {
+		return append(entriesPrefix(credentialsID), fmt.Sprintf("events/%s", entry.EventID)...)
+	}
+	return append(entriesPrefix(credentialsID), fmt.Sprintf("notifications/%s", entry.NotificationID)...)
+}
+
+// entryClassKey points to the latest entry of a class starting at the time, used to match notifications to entries.
+func entryClassKey(credentialsID string, entry *entry) []byte {
+	return []byte(fmt.Sprintf("statistics/%s/entries_by_class/%s/%s", credentialsID, entry.Time.UTC().Format(time.RFC3339), entry.DisplayName))
+}
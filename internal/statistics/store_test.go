@@ -0,0 +1,149 @@
+package statistics
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/kv"
+	"github.com/pilatescomplete-bot/internal/notifications"
+)
+
+func TestLedger(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	booked := func(id string, header string) *notifications.Notification {
+		return &notifications.Notification{ID: id, Type: notifications.NotificationTypeBooked, Body: header}
+	}
+	unbooked := func(id string, header string) *notifications.Notification {
+		return &notifications.Notification{ID: id, Type: notifications.NotificationTypeUnbooked, Body: header}
+	}
+	apply := func(nn ...*notifications.Notification) {
+		if err := store.Apply(ctx, "a", changesFromNotifications(ctx, nn), nn[len(nn)-1].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entryStatuses := func() map[string]EntryStatus {
+		entries, err := store.ListEntries(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses := map[string]EntryStatus{}
+		for _, entry := range entries {
+			statuses[entry.NotificationID] = entry.Status
+		}
+		return statuses
+	}
+
+	if _, err := store.Cursor(ctx, "a"); !errors.Is(err, ErrNoCursor) {
+		t.Fatalf("expected %v, got %v", ErrNoCursor, err)
+	}
+
+	apply(
+		booked("1", "Du är nu bokad på: Reformer 2024-09-01 11:15:00 hos Pilates Complete"),
+		// another class at the same time is not collapsed into the first one
+		booked("2", "Du är nu bokad på: Tower 2024-09-01 11:15:00 hos Pilates Complete"),
+		booked("3", "Du är nu bokad på: Barre 2024-09-02 11:15:00 hos Pilates Complete"),
+		booked("4", "garbage"),
+		&notifications.Notification{ID: "5", Type: notifications.NotificationTypeUnknown, Body: "Nyhetsbrev"},
+	)
+	if got, expected := entryStatuses(), map[string]EntryStatus{
+		"1": EntryStatusBooked,
+		"2": EntryStatusBooked,
+		"3": EntryStatusBooked,
+	}; !maps.Equal(got, expected) {
+		t.Fatalf("expected entries %v, got %v", expected, got)
+	}
+
+	// next sync only brings new notifications
+	apply(
+		unbooked("6", "Du är nu avbokad på: Barre 2024-09-02 11:15:00 hos Pilates Complete"),
+		unbooked("7", "Du är nu avbokad på: Tower 2024-09-03 11:15:00 hos Pilates Complete"),
+	)
+	// unbooked entries are kept to count cancellations
+	if got, expected := entryStatuses(), map[string]EntryStatus{
+		"1": EntryStatusBooked,
+		"2": EntryStatusBooked,
+		"6": EntryStatusUnbooked,
+		"7": EntryStatusUnbooked,
+	}; !maps.Equal(got, expected) {
+		t.Fatalf("expected entries %v, got %v", expected, got)
+	}
+
+	// outcomes replace booked status and keep the notification id
+	outcome := func(status EntryStatus, eventID string, displayName string, start time.Time) change {
+		return change{Entry: &entry{EventID: eventID, Time: start, DisplayName: displayName, Status: status}}
+	}
+	reformer := time.Date(2024, time.September, 1, 11, 15, 0, 0, time.FixedZone("CEST", 2*60*60))
+	if err := store.Apply(ctx, "a", []change{
+		outcome(EntryStatusChecked, "reformer", "Reformer", reformer),
+		outcome(EntryStatusMissed, "tower", "Tower", reformer),
+		outcome(EntryStatusChecked, "mat", "Mat", reformer.AddDate(0, 0, 7)),
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+	// a late booking notification does not overwrite the outcome
+	apply(booked("8", "Du är nu bokad på: Reformer 2024-09-01 11:15:00 hos Pilates Complete"))
+	if got, expected := entryStatuses(), map[string]EntryStatus{
+		"8": EntryStatusChecked,
+		"2": EntryStatusMissed,
+		"":  EntryStatusChecked,
+		"6": EntryStatusUnbooked,
+		"7": EntryStatusUnbooked,
+	}; !maps.Equal(got, expected) {
+		t.Fatalf("expected entries %v, got %v", expected, got)
+	}
+
+	// another event of the same class at the same time is not collapsed into the first one
+	if err := store.Apply(ctx, "a", []change{
+		outcome(EntryStatusChecked, "other-mat", "Mat", reformer.AddDate(0, 0, 7)),
+	}, ""); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.ListEntries(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventIDs := map[string]bool{}
+	for _, entry := range entries {
+		eventIDs[entry.EventID] = true
+	}
+	if expected := map[string]bool{"reformer": true, "tower": true, "mat": true, "other-mat": true, "": true}; !maps.Equal(eventIDs, expected) {
+		t.Fatalf("expected events %v, got %v", expected, eventIDs)
+	}
+
+	cursor, err := store.Cursor(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor != "8" {
+		t.Fatalf("expected cursor 8, got %q", cursor)
+	}
+}
+
+func TestSyncedAt(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+
+	if _, err := store.SyncedAt(ctx, "a"); !errors.Is(err, ErrNeverSynced) {
+		t.Fatalf("expected %v, got %v", ErrNeverSynced, err)
+	}
+	// a sync without notifications leaves the cursor unset, but is remembered
+	at := time.Date(2024, time.September, 1, 11, 15, 0, 0, time.UTC)
+	if err := store.MarkSynced(ctx, "a", at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Cursor(ctx, "a"); !errors.Is(err, ErrNoCursor) {
+		t.Fatalf("expected %v, got %v", ErrNoCursor, err)
+	}
+	syncedAt, err := store.SyncedAt(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !syncedAt.Equal(at) {
+		t.Fatalf("expected %s, got %s", at, syncedAt)
+	}
+}
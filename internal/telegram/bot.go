@@ -2,13 +2,17 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/pilatescomplete-bot/internal/authentication"
 	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/goals"
 	"github.com/pilatescomplete-bot/internal/jobs"
 )
 
@@ -48,7 +52,7 @@ func (b *Bot) BroadcastBookEventFailed(ctx context.Context, job *jobs.Job) error
 		return fmt.Errorf("get event: %w", err)
 	}
 	msg := &tgbotapi.MessageConfig{
-		Text: fmt.Sprintf("Failed to book %s on %s: %s", event.DisplayName, event.StartTime.Format("Monday Jan 02 at 15:04"), job.Errors[len(job.Errors)-1]),
+		Text: fmt.Sprintf("Failed to book %s on %s: %s", event.DisplayName, event.StartTime.Format("Monday Jan 02 at 15:04"), job.LastAttempt().Error),
 		Entities: []tgbotapi.MessageEntity{
 			{
 				Type:   "bold",
@@ -68,7 +72,7 @@ func (b *Bot) BroadcastEventBooked(ctx context.Context, job *jobs.Job) error {
 	if err != nil {
 		return fmt.Errorf("authenticate context: %w", err)
 	}
-	event, err := b.eventsService.GetEvent(ctx, job.BookEvent.EventID)
+	event, err := b.eventsService.GetEvent(ctx, job.BookedEventID())
 	if err != nil {
 		return fmt.Errorf("get event: %w", err)
 	}
@@ -88,6 +92,31 @@ func (b *Bot) BroadcastEventBooked(ctx context.Context, job *jobs.Job) error {
 	return nil
 }
 
+func (b *Bot) BroadcastBookEventMissed(ctx context.Context, job *jobs.Job) error {
+	ctx, err := b.authenticationService.AuthenticateContext(ctx, job.BookEvent.CredentialsID)
+	if err != nil {
+		return fmt.Errorf("authenticate context: %w", err)
+	}
+	event, err := b.eventsService.GetEvent(ctx, job.BookEvent.EventID)
+	if err != nil {
+		return fmt.Errorf("get event: %w", err)
+	}
+	msg := &tgbotapi.MessageConfig{
+		Text: fmt.Sprintf("Missed booking %s on %s, it was due at %s", event.DisplayName, event.StartTime.Format("Monday Jan 02 at 15:04"), job.Time.Format("15:04")),
+		Entities: []tgbotapi.MessageEntity{
+			{
+				Type:   "bold",
+				Offset: 15,
+				Length: len(event.DisplayName),
+			},
+		},
+	}
+	if err := b.broadcast(ctx, msg); err != nil {
+		return fmt.Errorf("broadcast: %w", err)
+	}
+	return nil
+}
+
 func (b *Bot) BroadcastSlogRecord(ctx context.Context, r slog.Record) error {
 	text := strings.Builder{}
 	text.WriteString(fmt.Sprintf("[%s] ", r.Level))
@@ -119,6 +148,35 @@ func (b *Bot) BroadcastSlogRecord(ctx context.Context, r slog.Record) error {
 	return nil
 }
 
+// SendGoalBehind tells the goal's user that they are behind, in chats linked to the user only.
+func (b *Bot) SendGoalBehind(ctx context.Context, progress *goals.Progress) error {
+	chats, err := b.store.ListChatsByCredentialsID(ctx, progress.Goal.CredentialsID)
+	if err != nil {
+		return fmt.Errorf("list chats: %w", err)
+	}
+	left := progress.Goal.Target - progress.Done
+	for _, chat := range chats {
+		msg := tgbotapi.NewMessage(chat.ID, fmt.Sprintf("The week is half over and %d of %d classes are attended, book %d more to reach the goal", progress.Done, progress.Goal.Target, left))
+		if _, err := b.api.Send(msg); err != nil {
+			return fmt.Errorf("send message: %w", err)
+		}
+	}
+	return nil
+}
+
+// linkCodeTTL is how long a link to the bot can be used to link a chat.
+const linkCodeTTL = 10 * time.Minute
+
+// NewLink returns a link to start a chat with the bot that is linked to the credentials id. The link can be used
+// once, within linkCodeTTL.
+func (b *Bot) NewLink(ctx context.Context, credentialsID string) (string, error) {
+	code := gonanoid.Must()
+	if err := b.store.InsertLinkCode(ctx, code, credentialsID, time.Now().Add(linkCodeTTL)); err != nil {
+		return "", fmt.Errorf("insert link code: %w", err)
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.api.Self.UserName, code), nil
+}
+
 func (b *Bot) broadcast(ctx context.Context, msg *tgbotapi.MessageConfig) error {
 	chats, err := b.store.ListChats(ctx)
 	if err != nil {
@@ -168,12 +226,37 @@ func (b *Bot) handleCommand(ctx context.Context, message *tgbotapi.Message) erro
 }
 
 func (b *Bot) handleStart(ctx context.Context, message *tgbotapi.Message) error {
-	chat := Chat{
+	chat := &Chat{
 		ID:        message.Chat.ID,
 		FirstName: message.Chat.FirstName,
 	}
-	if err := b.store.InsertChat(ctx, &chat); err != nil {
+	// starting the chat again keeps the link
+	if existing, err := b.store.FindChat(ctx, chat.ID); err == nil {
+		chat.CredentialsID = existing.CredentialsID
+	} else if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("find chat: %w", err)
+	}
+	linked := false
+	if code := message.CommandArguments(); code != "" {
+		credentialsID, err := b.store.ConsumeLinkCode(ctx, code)
+		if errors.Is(err, ErrNotFound) {
+			if _, err := b.api.Send(tgbotapi.NewMessage(chat.ID, "The link has expired, open a new one from the statistics page")); err != nil {
+				return fmt.Errorf("send message: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("consume link code: %w", err)
+		} else {
+			chat.CredentialsID = credentialsID
+			linked = true
+		}
+	}
+	if err := b.store.InsertChat(ctx, chat); err != nil {
 		return fmt.Errorf("insert chat: %w", err)
 	}
+	if linked {
+		if _, err := b.api.Send(tgbotapi.NewMessage(chat.ID, "Goal nudges will be sent to this chat")); err != nil {
+			return fmt.Errorf("send message: %w", err)
+		}
+	}
 	return nil
 }
@@ -3,4 +3,7 @@ package telegram
 type Chat struct {
 	ID        int64  `json:"id"`
 	FirstName string `json:"first_name"`
+	// CredentialsID is set when the chat was started from a link of the user, personal messages are sent to linked
+	// chats only.
+	CredentialsID string `json:"credentials_id,omitempty"`
 }
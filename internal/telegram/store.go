@@ -5,18 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
-	"github.com/dgraph-io/badger/v4"
-	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 type Store struct {
-	db            *badger.DB
-	encryptionKey *keys.Key
+	db kv.Store
 }
 
 func NewStore(
-	db *badger.DB,
+	db kv.Store,
 ) *Store {
 	return &Store{
 		db: db,
@@ -24,7 +23,7 @@ func NewStore(
 }
 
 func (s *Store) InsertChat(ctx context.Context, chat *Chat) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn kv.Txn) error {
 		data, err := json.Marshal(chat)
 		if err != nil {
 			return err
@@ -36,23 +35,37 @@ func (s *Store) InsertChat(ctx context.Context, chat *Chat) error {
 	})
 }
 
+// ErrNotFound is returned when a chat or a link code does not exist.
+var ErrNotFound = errors.New("not found")
+
+func (s *Store) FindChat(ctx context.Context, id int64) (*Chat, error) {
+	chat := &Chat{}
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get([]byte(fmt.Sprintf("telegram/chats/%d", id)))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(value, chat)
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return chat, nil
+}
+
 func (s *Store) ListChats(ctx context.Context) ([]Chat, error) {
 	chats := make([]Chat, 0)
-	if err := s.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		prefix := []byte("telegram/chats/")
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
+	if err := s.db.View(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("telegram/chats/"), func(key []byte, value []byte) error {
 			var chat Chat
-			if err := item.Value(func(value []byte) error {
-				return json.Unmarshal(value, &chat)
-			}); err != nil {
+			if err := json.Unmarshal(value, &chat); err != nil {
 				return err
 			}
 			chats = append(chats, chat)
-		}
-		return nil
+			return nil
+		})
 	}); err != nil {
 		return nil, err
 	}
@@ -60,7 +73,7 @@ func (s *Store) ListChats(ctx context.Context) ([]Chat, error) {
 }
 
 func (s *Store) SetUpdatesOffset(ctx context.Context, offset int) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn kv.Txn) error {
 		data := []byte(fmt.Sprintf("%d", offset))
 		return txn.Set([]byte("telegram/updates/offset"), data)
 	})
@@ -68,20 +81,83 @@ func (s *Store) SetUpdatesOffset(ctx context.Context, offset int) error {
 
 func (s *Store) GetUpdatesOffset(ctx context.Context) (int, error) {
 	var offset int
-	if err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("telegram/updates/offset"))
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get([]byte("telegram/updates/offset"))
 		if err != nil {
 			return err
 		}
-		return item.Value(func(value []byte) error {
-			_, err := fmt.Sscanf(string(value), "%d", &offset)
-			return err
-		})
+		_, err = fmt.Sscanf(string(value), "%d", &offset)
+		return err
 	}); err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
+		if errors.Is(err, kv.ErrNotFound) {
 			return 0, nil
 		}
 		return 0, err
 	}
 	return offset, nil
 }
+
+// ListChatsByCredentialsID returns chats linked to the credentials id.
+func (s *Store) ListChatsByCredentialsID(ctx context.Context, credentialsID string) ([]Chat, error) {
+	chats, err := s.ListChats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	linked := make([]Chat, 0)
+	for _, chat := range chats {
+		if chat.CredentialsID == credentialsID {
+			linked = append(linked, chat)
+		}
+	}
+	return linked, nil
+}
+
+type linkCode struct {
+	CredentialsID string    `json:"credentials_id"`
+	Expires       time.Time `json:"expires"`
+}
+
+// InsertLinkCode stores a one time code that links a chat to the credentials id until it expires.
+func (s *Store) InsertLinkCode(ctx context.Context, code string, credentialsID string, expires time.Time) error {
+	return s.db.Update(func(txn kv.Txn) error {
+		data, err := json.Marshal(linkCode{CredentialsID: credentialsID, Expires: expires})
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(fmt.Sprintf("telegram/link_codes/%s", code)), data)
+	})
+}
+
+// ConsumeLinkCode deletes the code and returns the credentials id it links to. Returns ErrNotFound if the code does
+// not exist or is expired.
+func (s *Store) ConsumeLinkCode(ctx context.Context, code string) (string, error) {
+	var credentialsID string
+	if err := s.db.Update(func(txn kv.Txn) error {
+		key := []byte(fmt.Sprintf("telegram/link_codes/%s", code))
+		value, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+		var link linkCode
+		if err := json.Unmarshal(value, &link); err != nil {
+			return err
+		}
+		// expired codes are deleted all the same
+		if time.Now().Before(link.Expires) {
+			credentialsID = link.CredentialsID
+		}
+		return nil
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	if credentialsID == "" {
+		return "", ErrNotFound
+	}
+	return credentialsID, nil
+}
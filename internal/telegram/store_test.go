@@ -2,17 +2,15 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
-	"github.com/dgraph-io/badger/v4"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 func TestStore(t *testing.T) {
-	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := kv.NewMemory()
 
 	store := NewStore(db)
 
@@ -46,11 +44,7 @@ func TestStore(t *testing.T) {
 }
 
 func TestUpdatesOffset(t *testing.T) {
-	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := kv.NewMemory()
 
 	store := NewStore(db)
 
@@ -69,3 +63,60 @@ func TestUpdatesOffset(t *testing.T) {
 		t.Fatalf("expected 100, got %d", offset)
 	}
 }
+
+func TestLinkCodes(t *testing.T) {
+	db := kv.NewMemory()
+
+	store := NewStore(db)
+
+	ctx := context.Background()
+
+	if err := store.InsertLinkCode(ctx, "code", "a", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("failed to insert link code: %v", err)
+	}
+	if err := store.InsertLinkCode(ctx, "expired", "a", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to insert link code: %v", err)
+	}
+
+	credentialsID, err := store.ConsumeLinkCode(ctx, "code")
+	if err != nil {
+		t.Fatalf("failed to consume link code: %v", err)
+	}
+	if credentialsID != "a" {
+		t.Fatalf("expected a, got %q", credentialsID)
+	}
+
+	// codes can be used once
+	if _, err := store.ConsumeLinkCode(ctx, "code"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+	if _, err := store.ConsumeLinkCode(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestListChatsByCredentialsID(t *testing.T) {
+	db := kv.NewMemory()
+
+	store := NewStore(db)
+
+	ctx := context.Background()
+
+	for _, chat := range []Chat{
+		{ID: 1, FirstName: "chat1", CredentialsID: "a"},
+		{ID: 2, FirstName: "chat2"},
+		{ID: 3, FirstName: "chat3", CredentialsID: "b"},
+	} {
+		if err := store.InsertChat(ctx, &chat); err != nil {
+			t.Fatalf("failed to insert chat: %v", err)
+		}
+	}
+
+	linked, err := store.ListChatsByCredentialsID(ctx, "a")
+	if err != nil {
+		t.Fatalf("failed to list chats: %v", err)
+	}
+	if len(linked) != 1 || linked[0].ID != 1 {
+		t.Fatalf("expected chat 1, got %v", linked)
+	}
+}
@@ -0,0 +1,164 @@
+package timetable
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// notSoldOut is a sell out time of classes that did not sell out, so that they sort last.
+const notSoldOut = time.Duration(math.MaxInt64)
+
+// minSamples is a number of classes that must have opened while collecting to tell how fast a group sells out.
+const minSamples = 3
+
+// SellOut describes how fast a group of classes sells out after opening.
+type SellOut struct {
+	Key string `json:"key"`
+	// Samples is a number of classes that were seen opening and either sold out or started.
+	Samples int `json:"samples"`
+	// SoldOut is a number of samples that sold out.
+	SoldOut int `json:"sold_out"`
+	// Median is a median time from opening until selling out, zero if most samples did not sell out.
+	Median time.Duration `json:"median"`
+}
+
+// UsuallySellsOut returns true if there are enough samples and most of them sold out.
+func (s *SellOut) UsuallySellsOut() bool {
+	return s.Samples >= minSamples && s.Median > 0
+}
+
+// Analysis is how fast classes sell out, grouped by class type, time slot and trainer. Groups are ordered from the
+// fastest to sell out.
+type Analysis struct {
+	ClassTypes []*SellOut `json:"class_types"`
+	TimeSlots  []*SellOut `json:"time_slots"`
+	Trainers   []*SellOut `json:"trainers"`
+
+	// classSlots are grouped by class type and time slot, used for hints
+	classSlots map[string]*SellOut
+	classTypes map[string]*SellOut
+	// started are series of started classes by class type, used to estimate promotions from the reserve list
+	started map[string][]*Series
+}
+
+// Hint returns how fast classes like the one usually sell out, by class type and time slot if there are enough
+// samples of it, otherwise by class type only. The second value is false if it's not known or they usually don't.
+func (a *Analysis) Hint(displayName string, startTime time.Time) (time.Duration, bool) {
+	for _, sellOut := range []*SellOut{
+		a.classSlots[classSlotKey(displayName, startTime)],
+		a.classTypes[displayName],
+	} {
+		if sellOut != nil && sellOut.Samples >= minSamples {
+			return sellOut.Median, sellOut.UsuallySellsOut()
+		}
+	}
+	return 0, false
+}
+
+// PromotionChance returns a share of past classes of the type, full at the lead time before the start, that promoted
+// at least as many reserves as the position by the start. The second value is false if there are not enough of them.
+func (a *Analysis) PromotionChance(displayName string, position int64, lead time.Duration) (float64, bool) {
+	samples, promoted := 0, 0
+	for _, s := range a.started[displayName] {
+		promotions, ok := s.PromotionsAfter(s.StartTime.Add(-lead))
+		if !ok {
+			continue
+		}
+		samples++
+		if int64(promotions) >= position {
+			promoted++
+		}
+	}
+	if samples < minSamples {
+		return 0, false
+	}
+	return float64(promoted) / float64(samples), true
+}
+
+func timeSlotKey(startTime time.Time) string {
+	return fmt.Sprintf("%s %s", startTime.Weekday(), startTime.Format("15:04"))
+}
+
+func classSlotKey(displayName string, startTime time.Time) string {
+	return fmt.Sprintf("%s, %s", displayName, timeSlotKey(startTime))
+}
+
+// analyze groups sell out times of series that were seen opening, and either sold out or started by now.
+func analyze(series []*Series, now time.Time) *Analysis {
+	type group map[string][]time.Duration
+	classTypes, timeSlots, trainers, classSlots := group{}, group{}, group{}, group{}
+	started := map[string][]*Series{}
+	for _, s := range series {
+		if s.StartTime.Before(now) {
+			started[s.DisplayName] = append(started[s.DisplayName], s)
+		}
+		after, observed, soldOut := s.SoldOutAfter()
+		if !observed || (!soldOut && s.StartTime.After(now)) {
+			continue
+		}
+		if !soldOut {
+			after = notSoldOut
+		}
+		classTypes[s.DisplayName] = append(classTypes[s.DisplayName], after)
+		timeSlots[timeSlotKey(s.StartTime)] = append(timeSlots[timeSlotKey(s.StartTime)], after)
+		if s.TrainerName != "" {
+			trainers[s.TrainerName] = append(trainers[s.TrainerName], after)
+		}
+		classSlots[classSlotKey(s.DisplayName, s.StartTime)] = append(classSlots[classSlotKey(s.DisplayName, s.StartTime)], after)
+	}
+	sellOuts := func(g group) map[string]*SellOut {
+		sellOuts := make(map[string]*SellOut, len(g))
+		for key, durations := range g {
+			sellOuts[key] = newSellOut(key, durations)
+		}
+		return sellOuts
+	}
+	analysis := &Analysis{
+		classSlots: sellOuts(classSlots),
+		classTypes: sellOuts(classTypes),
+		started:    started,
+	}
+	analysis.ClassTypes = sortedSellOuts(analysis.classTypes)
+	analysis.TimeSlots = sortedSellOuts(sellOuts(timeSlots))
+	analysis.Trainers = sortedSellOuts(sellOuts(trainers))
+	return analysis
+}
+
+// newSellOut returns a sell out of durations, where classes that did not sell out have the maximum duration.
+func newSellOut(key string, durations []time.Duration) *SellOut {
+	slices.Sort(durations)
+	sellOut := &SellOut{
+		Key:     key,
+		Samples: len(durations),
+	}
+	for _, d := range durations {
+		if d != notSoldOut {
+			sellOut.SoldOut++
+		}
+	}
+	if median := durations[(len(durations)-1)/2]; median != notSoldOut {
+		sellOut.Median = median
+	}
+	return sellOut
+}
+
+func sortedSellOuts(sellOuts map[string]*SellOut) []*SellOut {
+	sorted := make([]*SellOut, 0, len(sellOuts))
+	for _, sellOut := range sellOuts {
+		sorted = append(sorted, sellOut)
+	}
+	slices.SortFunc(sorted, func(a, b *SellOut) int {
+		if a.UsuallySellsOut() != b.UsuallySellsOut() {
+			if a.UsuallySellsOut() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(cmp.Compare(a.Median, b.Median), strings.Compare(a.Key, b.Key))
+	})
+	return sorted
+}
@@ -0,0 +1,117 @@
+package timetable
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	now := time.Date(2025, time.March, 31, 12, 0, 0, 0, time.UTC)
+	// Monday 17:45
+	monday := time.Date(2025, time.March, 3, 17, 45, 0, 0, time.UTC)
+	series := func(name, trainer string, start time.Time, fullAfter time.Duration) *Series {
+		opening := start.AddDate(0, 0, -7)
+		s := &Series{
+			DisplayName:  name,
+			TrainerName:  trainer,
+			StartTime:    start,
+			BookableFrom: opening,
+			Snapshots:    []Snapshot{{Time: opening, PlacesTotal: 10}},
+		}
+		if fullAfter > 0 {
+			s.Snapshots = append(s.Snapshots, Snapshot{Time: opening.Add(fullAfter), PlacesTaken: 10, PlacesTotal: 10})
+		}
+		return s
+	}
+
+	analysis := analyze([]*Series{
+		series("Reformer", "Anna", monday, 2*time.Minute),
+		series("Reformer", "Anna", monday.AddDate(0, 0, 7), 3*time.Minute),
+		series("Reformer", "Bea", monday.AddDate(0, 0, 14), 10*time.Minute),
+		series("Reformer", "Bea", monday.AddDate(0, 0, 1), time.Hour),
+		// did not sell out and already started
+		series("Reformer", "Bea", monday.AddDate(0, 0, 8), 0),
+		series("Mat", "Anna", monday.AddDate(0, 0, 1), 0),
+		series("Mat", "Anna", monday.AddDate(0, 0, 8), 0),
+		series("Mat", "Anna", monday.AddDate(0, 0, 15), time.Hour),
+		// did not sell out yet, not counted
+		series("Mat", "Anna", now.Add(time.Hour), 0),
+		// seen full only, not known when it sold out
+		{DisplayName: "Mat", StartTime: monday, Snapshots: []Snapshot{{Time: monday, PlacesTaken: 10, PlacesTotal: 10}}},
+	}, now)
+
+	if within, ok := analysis.Hint("Reformer", monday.AddDate(0, 0, 28)); !ok || within != 3*time.Minute {
+		t.Fatalf("expected Monday Reformer full within 3m, got %s %t", within, ok)
+	}
+	// not enough samples of Tuesday Reformer, falls back to all Reformer classes
+	if within, ok := analysis.Hint("Reformer", monday.AddDate(0, 0, 29)); !ok || within != 10*time.Minute {
+		t.Fatalf("expected Reformer full within 10m, got %s %t", within, ok)
+	}
+	if within, ok := analysis.Hint("Mat", monday); ok {
+		t.Fatalf("expected Mat to not usually sell out, got %s", within)
+	}
+	if _, ok := analysis.Hint("Barre", monday); ok {
+		t.Fatal("expected no hint for unknown class")
+	}
+
+	if len(analysis.ClassTypes) != 2 || analysis.ClassTypes[0].Key != "Reformer" || analysis.ClassTypes[0].SoldOut != 4 || analysis.ClassTypes[0].Samples != 5 {
+		t.Fatalf("expected Reformer to sell out fastest, got %+v", analysis.ClassTypes)
+	}
+	if expected := "Monday 17:45"; analysis.TimeSlots[0].Key != expected {
+		t.Fatalf("expected %s to sell out fastest, got %+v", expected, analysis.TimeSlots[0])
+	}
+}
+
+func TestPromotionChance(t *testing.T) {
+	now := time.Date(2025, time.March, 31, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2025, time.March, 3, 17, 45, 0, 0, time.UTC)
+	// series is full with 3 reserves a day before the start, and releases places at the times before the start
+	series := func(start time.Time, releases ...time.Duration) *Series {
+		s := &Series{
+			DisplayName: "Reformer",
+			StartTime:   start,
+			Snapshots: []Snapshot{
+				{Time: start.Add(-48 * time.Hour), PlacesTotal: 10, PlacesTaken: 9},
+				{Time: start.Add(-24 * time.Hour), PlacesTotal: 10, PlacesTaken: 10, ReservesTaken: 3, ReservesTotal: 5},
+			},
+		}
+		reserves := int64(3)
+		for _, before := range releases {
+			reserves--
+			s.Snapshots = append(s.Snapshots, Snapshot{
+				Time:          start.Add(-before),
+				PlacesTotal:   10,
+				PlacesTaken:   10,
+				ReservesTaken: reserves,
+				ReservesTotal: 5,
+			})
+		}
+		return s
+	}
+
+	analysis := analyze([]*Series{
+		series(start, 12*time.Hour, time.Hour),
+		series(start.AddDate(0, 0, 7), 2*time.Hour),
+		series(start.AddDate(0, 0, 14)),
+		series(start.AddDate(0, 0, 21), 30*time.Minute),
+		// not started yet, not counted
+		series(now.Add(time.Hour), 2*time.Hour, time.Hour),
+	}, now)
+
+	if chance, ok := analysis.PromotionChance("Reformer", 1, 24*time.Hour); !ok || chance != 0.75 {
+		t.Fatalf("expected 3/4 chance for first reserve a day before, got %f %t", chance, ok)
+	}
+	if chance, ok := analysis.PromotionChance("Reformer", 2, 24*time.Hour); !ok || chance != 0.25 {
+		t.Fatalf("expected 1/4 chance for second reserve a day before, got %f %t", chance, ok)
+	}
+	if chance, ok := analysis.PromotionChance("Reformer", 1, 90*time.Minute); !ok || chance != 0.5 {
+		t.Fatalf("expected 1/2 chance for first reserve 90 minutes before, got %f %t", chance, ok)
+	}
+	// classes were not full two days before
+	if chance, ok := analysis.PromotionChance("Reformer", 1, 48*time.Hour); ok {
+		t.Fatalf("expected no chance two days before, got %f", chance)
+	}
+	if _, ok := analysis.PromotionChance("Mat", 1, 24*time.Hour); ok {
+		t.Fatal("expected no chance for unknown class")
+	}
+}
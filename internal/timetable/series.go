@@ -0,0 +1,128 @@
+package timetable
+
+import (
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/events"
+)
+
+// Series is a time series of an event's places, recorded every time they changed.
+type Series struct {
+	EventID             string     `json:"event_id"`
+	DisplayName         string     `json:"display_name"`
+	TrainerName         string     `json:"trainer_name"`
+	LocationDisplayName string     `json:"location_display_name"`
+	StartTime           time.Time  `json:"start_time"`
+	BookableFrom        time.Time  `json:"bookable_from"`
+	Snapshots           []Snapshot `json:"snapshots"`
+}
+
+type Snapshot struct {
+	Time          time.Time `json:"time"`
+	PlacesTaken   int64     `json:"places_taken"`
+	PlacesTotal   int64     `json:"places_total"`
+	ReservesTaken int64     `json:"reserves_taken"`
+	ReservesTotal int64     `json:"reserves_total"`
+}
+
+func snapshotFromEvent(event *events.Event, now time.Time) Snapshot {
+	return Snapshot{
+		Time:          now,
+		PlacesTaken:   event.PlacesTaken,
+		PlacesTotal:   event.PlacesTotal,
+		ReservesTaken: event.ReservesTaken,
+		ReservesTotal: event.ReservesTotal,
+	}
+}
+
+func (s Snapshot) IsFull() bool {
+	return s.PlacesTotal > 0 && s.PlacesTaken >= s.PlacesTotal
+}
+
+// sameCounts returns true if places of both snapshots are the same.
+func (s Snapshot) sameCounts(other Snapshot) bool {
+	return s.PlacesTaken == other.PlacesTaken &&
+		s.PlacesTotal == other.PlacesTotal &&
+		s.ReservesTaken == other.ReservesTaken &&
+		s.ReservesTotal == other.ReservesTotal
+}
+
+// record updates the series with details of the event and appends a snapshot if places changed.
+// Returns true if the series changed.
+func (s *Series) record(event *events.Event, now time.Time) bool {
+	changed := s.DisplayName != event.DisplayName ||
+		s.TrainerName != event.TrainerName ||
+		s.LocationDisplayName != event.LocationDisplayName ||
+		!s.StartTime.Equal(event.StartTime) ||
+		!s.BookableFrom.Equal(event.BookableFrom)
+	s.EventID = event.ID
+	s.DisplayName = event.DisplayName
+	s.TrainerName = event.TrainerName
+	s.LocationDisplayName = event.LocationDisplayName
+	s.StartTime = event.StartTime
+	s.BookableFrom = event.BookableFrom
+
+	snapshot := snapshotFromEvent(event, now)
+	if len(s.Snapshots) > 0 && s.Snapshots[len(s.Snapshots)-1].sameCounts(snapshot) {
+		return changed
+	}
+	s.Snapshots = append(s.Snapshots, snapshot)
+	return true
+}
+
+// SoldOutAfter returns how long after opening the event was first seen full. The second value is false if the event
+// was never seen with free places, so it's not known when it sold out, and the third if it did not sell out.
+func (s *Series) SoldOutAfter() (time.Duration, bool, bool) {
+	observed := false
+	for _, snapshot := range s.Snapshots {
+		if !snapshot.IsFull() {
+			observed = true
+			continue
+		}
+		if !observed {
+			return 0, false, false
+		}
+		// snapshots are discrete, so a class full on the first snapshot after opening is counted as a minute
+		return max(snapshot.Time.Sub(s.BookableFrom), time.Minute), true, true
+	}
+	return 0, observed, false
+}
+
+// at returns the snapshot in effect at the time, false if the series was not recorded yet.
+func (s *Series) at(t time.Time) (Snapshot, bool) {
+	var current Snapshot
+	found := false
+	for _, snapshot := range s.Snapshots {
+		if snapshot.Time.After(t) {
+			break
+		}
+		current = snapshot
+		found = true
+	}
+	return current, found
+}
+
+// PromotionsAfter returns how many reserves got a place from the time until the start. Places are taken by reserves
+// as soon as they are freed, so every shrink of the reserve list of a full class is counted as a promotion, also when
+// a reserve left the list. The second value is false if the class was not recorded at the time, or was not full then
+// so there was no reserve list.
+func (s *Series) PromotionsAfter(t time.Time) (int, bool) {
+	previous, ok := s.at(t)
+	if !ok || !previous.IsFull() {
+		return 0, false
+	}
+	promotions := 0
+	for _, snapshot := range s.Snapshots {
+		if !snapshot.Time.After(t) {
+			continue
+		}
+		if snapshot.Time.After(s.StartTime) {
+			break
+		}
+		if previous.IsFull() && snapshot.ReservesTaken < previous.ReservesTaken {
+			promotions += int(previous.ReservesTaken - snapshot.ReservesTaken)
+		}
+		previous = snapshot
+	}
+	return promotions, true
+}
@@ -0,0 +1,153 @@
+package timetable
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/authentication"
+	"github.com/pilatescomplete-bot/internal/credentials"
+	"github.com/pilatescomplete-bot/internal/events"
+)
+
+// pruneInterval is how often series are pruned. They are kept for long after the start, so pruning once a day is
+// enough.
+const pruneInterval = 24 * time.Hour
+
+type Service struct {
+	store                 *Store
+	eventsService         *events.Service
+	authenticationService *authentication.Service
+	credentialsStore      *credentials.Store
+
+	analysisGuard sync.RWMutex
+	// analysis is computed once per collection, nil until the first one
+	analysis *Analysis
+}
+
+func NewService(
+	store *Store,
+	eventsService *events.Service,
+	authenticationService *authentication.Service,
+	credentialsStore *credentials.Store,
+) *Service {
+	return &Service{
+		store:                 store,
+		eventsService:         eventsService,
+		authenticationService: authenticationService,
+		credentialsStore:      credentialsStore,
+	}
+}
+
+// Run snapshots the timetable and analyzes it every interval, and prunes series of events that started longer than
+// retention ago once a day, until context is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration, retention time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var prunedAt time.Time
+	for {
+		if time.Since(prunedAt) >= pruneInterval {
+			if _, err := s.store.Prune(ctx, time.Now().Add(-retention)); err != nil {
+				slog.ErrorContext(ctx, "prune timetable", "error", err)
+			} else {
+				prunedAt = time.Now()
+			}
+		}
+		if err := s.Collect(ctx); err != nil {
+			slog.ErrorContext(ctx, "collect timetable", "error", err)
+		} else if _, err := s.refreshAnalysis(ctx); err != nil {
+			slog.ErrorContext(ctx, "analyze timetable", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "stopping timetable collector")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect snapshots the timetable. The timetable is the same for everyone, so it is listed with the first
+// credentials that authenticate.
+func (s *Service) Collect(ctx context.Context) error {
+	credentialsIDs, err := s.credentialsStore.ListIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("list credentials: %w", err)
+	}
+	if len(credentialsIDs) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(credentialsIDs))
+	for _, credentialsID := range credentialsIDs {
+		authenticated, err := s.authenticationService.AuthenticateContext(ctx, credentialsID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", credentialsID, err))
+			continue
+		}
+		return s.collect(authenticated)
+	}
+	return fmt.Errorf("authenticate context: %w", errors.Join(errs...))
+}
+
+func (s *Service) collect(ctx context.Context) error {
+	ee, err := s.eventsService.ListEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	if _, err := s.store.Record(ctx, ee, time.Now()); err != nil {
+		return fmt.Errorf("record: %w", err)
+	}
+	return nil
+}
+
+// Analyze returns the analysis of the last collection, it is only computed here if there was none yet.
+func (s *Service) Analyze(ctx context.Context) (*Analysis, error) {
+	s.analysisGuard.RLock()
+	analysis := s.analysis
+	s.analysisGuard.RUnlock()
+	if analysis != nil {
+		return analysis, nil
+	}
+	return s.refreshAnalysis(ctx)
+}
+
+// refreshAnalysis analyzes all series and keeps the analysis until the next collection.
+func (s *Service) refreshAnalysis(ctx context.Context) (*Analysis, error) {
+	series, err := s.store.ListSeries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list series: %w", err)
+	}
+	analysis := analyze(series, time.Now())
+	s.analysisGuard.Lock()
+	s.analysis = analysis
+	s.analysisGuard.Unlock()
+	return analysis, nil
+}
+
+// SetHints sets how fast similar classes usually sell out on events that are not booked yet, and the chance to get
+// a place on events reserved by the user.
+func (s *Service) SetHints(ctx context.Context, ee []*events.Event) error {
+	analysis, err := s.Analyze(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, event := range ee {
+		switch {
+		case event.Booking == nil:
+			if within, ok := analysis.Hint(event.DisplayName, event.StartTime); ok {
+				event.UsuallyFullWithin = within
+			}
+		case event.Booking.IsReserved() && event.Booking.Position > 0 && event.StartTime.After(now):
+			if chance, ok := analysis.PromotionChance(event.DisplayName, event.Booking.Position, event.StartTime.Sub(now)); ok {
+				percent := int(math.Round(chance * 100))
+				event.PromotionChance = &percent
+			}
+		}
+	}
+	return nil
+}
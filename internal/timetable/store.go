@@ -0,0 +1,153 @@
+package timetable
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// errKept stops iteration of the start time index at the first series that is kept.
+var errKept = errors.New("kept")
+
+// startLayout formats start times in the index, so that keys sort by the start time.
+const startLayout = "2006-01-02T15:04:05Z"
+
+// Store keeps a series of snapshots per event.
+type Store struct {
+	db kv.Store
+}
+
+func NewStore(db kv.Store) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Record snapshots places of the events. Returns number of series that changed.
+func (s *Store) Record(_ context.Context, ee []*events.Event, now time.Time) (int, error) {
+	changed := 0
+	if err := s.db.Update(func(txn kv.Txn) error {
+		for _, event := range ee {
+			series := &Series{}
+			value, err := txn.Get(seriesKey(event.ID))
+			switch {
+			case errors.Is(err, kv.ErrNotFound):
+			case err != nil:
+				return err
+			default:
+				if err := json.Unmarshal(value, series); err != nil {
+					return err
+				}
+			}
+			previous := *series
+			if !series.record(event, now) {
+				continue
+			}
+			if previous.EventID == "" || !previous.StartTime.Equal(series.StartTime) {
+				if previous.EventID != "" {
+					if err := txn.Delete(startIndexKey(&previous)); err != nil {
+						return err
+					}
+				}
+				if err := txn.Set(startIndexKey(series), seriesKey(series.EventID)); err != nil {
+					return err
+				}
+			}
+			data, err := json.Marshal(series)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(seriesKey(event.ID), data); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+func (s *Store) Get(_ context.Context, eventID string) (*Series, error) {
+	series := &Series{}
+	if err := s.db.View(func(txn kv.Txn) error {
+		value, err := txn.Get(seriesKey(eventID))
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(value, series)
+	}); err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return series, nil
+}
+
+func (s *Store) ListSeries(_ context.Context) ([]*Series, error) {
+	var series []*Series
+	if err := s.db.View(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("timetable/"), func(_ []byte, value []byte) error {
+			s := &Series{}
+			if err := json.Unmarshal(value, s); err != nil {
+				return err
+			}
+			series = append(series, s)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// Prune deletes series of events that started before the time. Returns number of deleted series.
+func (s *Store) Prune(_ context.Context, before time.Time) (int, error) {
+	pruned := 0
+	if err := s.db.Update(func(txn kv.Txn) error {
+		var indexKeys, keys [][]byte
+		bound := startIndexPrefix(before)
+		if err := txn.Iterate([]byte("timetable_by_start/"), func(key []byte, value []byte) error {
+			if bytes.Compare(key, bound) >= 0 {
+				return errKept
+			}
+			indexKeys = append(indexKeys, bytes.Clone(key))
+			keys = append(keys, bytes.Clone(value))
+			return nil
+		}); err != nil && !errors.Is(err, errKept) {
+			return err
+		}
+		for _, key := range append(indexKeys, keys...) {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		pruned = len(keys)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
+func seriesKey(eventID string) []byte {
+	return []byte(fmt.Sprintf("timetable/%s", eventID))
+}
+
+func startIndexPrefix(startTime time.Time) []byte {
+	return []byte(fmt.Sprintf("timetable_by_start/%s/", startTime.UTC().Format(startLayout)))
+}
+
+func startIndexKey(series *Series) []byte {
+	return append(startIndexPrefix(series.StartTime), series.EventID...)
+}
@@ -0,0 +1,85 @@
+package timetable
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pilatescomplete-bot/internal/events"
+	"github.com/pilatescomplete-bot/internal/kv"
+)
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+	now := time.Date(2025, time.March, 3, 8, 0, 0, 0, time.UTC)
+	event := &events.Event{
+		ID:           "1",
+		DisplayName:  "Reformer",
+		StartTime:    now.AddDate(0, 0, 7),
+		BookableFrom: now,
+		PlacesTotal:  10,
+	}
+
+	record := func(taken int64, at time.Time) int {
+		event.PlacesTaken = taken
+		changed, err := store.Record(ctx, []*events.Event{event}, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changed
+	}
+	if changed := record(0, now); changed != 1 {
+		t.Fatalf("expected new series, got %d changed", changed)
+	}
+	if changed := record(0, now.Add(time.Minute)); changed != 0 {
+		t.Fatalf("expected no snapshot when places did not change, got %d changed", changed)
+	}
+	record(4, now.Add(2*time.Minute))
+	record(10, now.Add(3*time.Minute))
+	record(9, now.Add(4*time.Minute))
+
+	series, err := store.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Snapshots) != 4 {
+		t.Fatalf("expected 4 snapshots, got %+v", series.Snapshots)
+	}
+	after, observed, soldOut := series.SoldOutAfter()
+	if !observed || !soldOut || after != 3*time.Minute {
+		t.Fatalf("expected sold out after 3m, got %s %t %t", after, observed, soldOut)
+	}
+
+	if pruned, err := store.Prune(ctx, event.StartTime); err != nil || pruned != 0 {
+		t.Fatalf("expected nothing pruned, got %d %v", pruned, err)
+	}
+	if pruned, err := store.Prune(ctx, event.StartTime.Add(time.Second)); err != nil || pruned != 1 {
+		t.Fatalf("expected series pruned, got %d %v", pruned, err)
+	}
+	if _, err := store.Get(ctx, "1"); err != ErrNotFound {
+		t.Fatalf("expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestPruneMovedEvent(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(kv.NewMemory())
+	now := time.Date(2025, time.March, 3, 8, 0, 0, 0, time.UTC)
+	event := &events.Event{ID: "1", DisplayName: "Reformer", StartTime: now, BookableFrom: now.AddDate(0, 0, -7)}
+
+	if _, err := store.Record(ctx, []*events.Event{event}, now.AddDate(0, 0, -7)); err != nil {
+		t.Fatal(err)
+	}
+	event.StartTime = now.Add(time.Hour)
+	if _, err := store.Record(ctx, []*events.Event{event}, now.AddDate(0, 0, -6)); err != nil {
+		t.Fatal(err)
+	}
+
+	if pruned, err := store.Prune(ctx, now.Add(time.Minute)); err != nil || pruned != 0 {
+		t.Fatalf("expected moved series kept, got %d %v", pruned, err)
+	}
+	if pruned, err := store.Prune(ctx, now.Add(2*time.Hour)); err != nil || pruned != 1 {
+		t.Fatalf("expected series pruned, got %d %v", pruned, err)
+	}
+}
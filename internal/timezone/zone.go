@@ -15,3 +15,8 @@ var stockholmLocation = mustLoadLocation("Europe/Stockholm")
 func InStockholm(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), stockholmLocation)
 }
+
+// ToStockholm returns the same instant as the time, in Stockholm.
+func ToStockholm(t time.Time) time.Time {
+	return t.In(stockholmLocation)
+}
@@ -9,37 +9,36 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
 )
 
 type Store struct {
-	db            *badger.DB
-	encryptionKey *keys.Key
+	db      kv.Store
+	keyring *keys.Keyring
 }
 
 func NewStore(
-	db *badger.DB,
-	encryptionKey *keys.Key,
+	db kv.Store,
+	keyring *keys.Keyring,
 ) *Store {
 	return &Store{
-		db:            db,
-		encryptionKey: encryptionKey,
+		db:      db,
+		keyring: keyring,
 	}
 }
 
 var ErrNotFound = errors.New("not found")
 
+// errFound stops iteration once a token is found.
+var errFound = errors.New("found")
+
 // FindByID returns first token for credentials id that did not expire.
 func (s *Store) FindByID(ctx context.Context, credentialsID string) (*Token, error) {
 	var token EncodedToken
-	if err := s.db.View(func(txn *badger.Txn) error {
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-		prefix := []byte(fmt.Sprintf("tokens/%s", credentialsID))
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			key := item.Key()
+	if err := s.db.View(func(txn kv.Txn) error {
+		prefix := []byte(fmt.Sprintf("tokens/%s/", credentialsID))
+		err := txn.Iterate(prefix, func(key []byte, value []byte) error {
 			keyParts := bytes.Split(key, []byte("/"))
 			ts, err := strconv.ParseInt(string(keyParts[2]), 10, 64)
 			if err != nil {
@@ -47,24 +46,31 @@ func (s *Store) FindByID(ctx context.Context, credentialsID string) (*Token, err
 			}
 			t := time.Unix(ts, 0)
 			if t.After(time.Now()) {
-				return item.Value(func(value []byte) error {
-					return json.Unmarshal(value, &token)
-				})
+				if err := json.Unmarshal(value, &token); err != nil {
+					return err
+				}
+				return errFound
 			}
+			return nil
+		})
+		if errors.Is(err, errFound) {
+			return nil
+		} else if err != nil {
+			return err
 		}
 		return ErrNotFound
 	}); err != nil {
 		return nil, err
 	}
-	return token.Decode(s.encryptionKey)
+	return token.Decode(s.keyring)
 }
 
 func (s *Store) Insert(ctx context.Context, token *Token) error {
-	encoded, err := token.Encode(s.encryptionKey)
+	encoded, err := token.Encode(s.keyring)
 	if err != nil {
 		return err
 	}
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.db.Update(func(txn kv.Txn) error {
 		data, err := json.Marshal(encoded)
 		if err != nil {
 			return err
@@ -75,3 +81,65 @@ func (s *Store) Insert(ctx context.Context, token *Token) error {
 		return nil
 	})
 }
+
+// Reencrypt encrypts all tokens that are not encrypted with the active key with it.
+// Returns number of re-encrypted tokens.
+func (s *Store) Reencrypt(ctx context.Context) (int, error) {
+	count := 0
+	if err := s.db.Update(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("tokens/"), func(key []byte, value []byte) error {
+			var encoded EncodedToken
+			if err := json.Unmarshal(value, &encoded); err != nil {
+				return err
+			}
+			if encoded.KeyID == s.keyring.ActiveID() {
+				return nil
+			}
+			token, err := encoded.Decode(s.keyring)
+			if err != nil {
+				return fmt.Errorf("decode %q: %w", key, err)
+			}
+			reencoded, err := token.Encode(s.keyring)
+			if err != nil {
+				return fmt.Errorf("encode %q: %w", key, err)
+			}
+			data, err := json.Marshal(reencoded)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(key, data); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteExpired deletes all tokens that expired before the given time. Returns number of deleted tokens.
+func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	count := 0
+	if err := s.db.Update(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("tokens/"), func(key []byte, _ []byte) error {
+			keyParts := bytes.Split(key, []byte("/"))
+			ts, err := strconv.ParseInt(string(keyParts[2]), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%q: %w", key, err)
+			}
+			if !time.Unix(ts, 0).Before(before) {
+				return nil
+			}
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -6,24 +6,20 @@ import (
 	"testing"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/pilatescomplete-bot/internal/keys"
+	"github.com/pilatescomplete-bot/internal/kv"
 	"github.com/pilatescomplete-bot/internal/tokens"
 )
 
 func TestFind(t *testing.T) {
-	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := kv.NewMemory()
 
 	key, err := keys.NewKey()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	store := tokens.NewStore(db, key)
+	store := tokens.NewStore(db, keys.NewKeyring(keys.DefaultKeyID, key))
 
 	inserted := tokens.Token{
 		CredentialsID: "id",
@@ -59,18 +55,14 @@ func TestFind(t *testing.T) {
 }
 
 func TestFindExpired(t *testing.T) {
-	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := kv.NewMemory()
 
 	key, err := keys.NewKey()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	store := tokens.NewStore(db, key)
+	store := tokens.NewStore(db, keys.NewKeyring(keys.DefaultKeyID, key))
 
 	inserted := tokens.Token{
 		CredentialsID: "id",
@@ -87,3 +79,52 @@ func TestFindExpired(t *testing.T) {
 		t.Fatalf("expected %q, got %q", tokens.ErrNotFound, err)
 	}
 }
+
+func TestDeleteExpired(t *testing.T) {
+	db := kv.NewMemory()
+
+	key, err := keys.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := tokens.NewStore(db, keys.NewKeyring(keys.DefaultKeyID, key))
+
+	valid := tokens.Token{
+		CredentialsID: "valid",
+		Token:         "token",
+		Expires:       time.Now().Add(100 * time.Second),
+	}
+	expired := tokens.Token{
+		CredentialsID: "expired",
+		Token:         "token",
+		Expires:       time.Now().Add(-100 * time.Second),
+	}
+
+	ctx := context.Background()
+	for _, token := range []*tokens.Token{&valid, &expired} {
+		if err := store.Insert(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := store.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 deleted token, got %d", deleted)
+	}
+
+	if _, err := store.FindByID(ctx, valid.CredentialsID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.View(func(txn kv.Txn) error {
+		return txn.Iterate([]byte("tokens/expired/"), func(key []byte, _ []byte) error {
+			t.Errorf("unexpected key %q", key)
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
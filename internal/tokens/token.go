@@ -12,8 +12,8 @@ type Token struct {
 	Expires       time.Time `json:"time"`
 }
 
-func (c Token) Encode(key *keys.Key) (*EncodedToken, error) {
-	encoded, err := key.Encrypt([]byte(c.Token))
+func (c Token) Encode(keyring *keys.Keyring) (*EncodedToken, error) {
+	keyID, encoded, err := keyring.Encrypt([]byte(c.Token))
 	if err != nil {
 		return nil, err
 	}
@@ -21,6 +21,7 @@ func (c Token) Encode(key *keys.Key) (*EncodedToken, error) {
 		CredentialsID: c.CredentialsID,
 		Token:         encoded,
 		Expires:       c.Expires,
+		KeyID:         keyID,
 	}, nil
 }
 
@@ -28,10 +29,12 @@ type EncodedToken struct {
 	CredentialsID string    `json:"credentials_id"`
 	Token         []byte    `json:"token"`
 	Expires       time.Time `json:"time"`
+	// KeyID is an id of the key token is encrypted with.
+	KeyID string `json:"key_id,omitempty"`
 }
 
-func (e EncodedToken) Decode(key *keys.Key) (*Token, error) {
-	token, err := key.Decrypt(e.Token)
+func (e EncodedToken) Decode(keyring *keys.Keyring) (*Token, error) {
+	token, err := keyring.Decrypt(e.KeyID, e.Token)
 	if err != nil {
 		return nil, err
 	}